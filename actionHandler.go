@@ -6,59 +6,146 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"strconv"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 func handlerLogin(state *state, cmd command) error {
-	if len(cmd.args) == 0 {
-		return errors.New("username argument is required")
+	username := cmd.value("username")
+	user, err := state.dbQueriesData.GetUser(context.Background(), username)
+	if err != nil {
+		return fmt.Errorf("user %s does not exists", username)
 	}
 
-	_, err := state.dbQueriesData.GetUser(context.Background(), cmd.args[0])
-	if err != nil {
-		return fmt.Errorf("user %s does not exists", cmd.args[0])
+	apiKey := cmd.value("--api-key")
+	if apiKey != "" || state.configData.Strict {
+		if err := checkAPIKey(user, apiKey); err != nil {
+			return fmt.Errorf("cannot log in as %s: %v", username, err)
+		}
 	}
 
-	if err := state.configData.SetUser(cmd.args[0]); err != nil {
+	if err := state.configData.SetCredentials(username, apiKey); err != nil {
 		return err
 	}
 
-	fmt.Println("Logged in as", cmd.args[0])
+	fmt.Println("Logged in as", username)
 	return nil
 }
 
 func handlerRegister(state *state, cmd command) error {
-	if len(cmd.args) == 0 {
-		return errors.New("username argument is required")
+	username := cmd.value("username")
+	_, err := state.dbQueriesData.GetUser(context.Background(), username)
+	if err == nil {
+		return fmt.Errorf("user %s already exists", username)
 	}
 
-	_, err := state.dbQueriesData.GetUser(context.Background(), cmd.args[0])
-	if err == nil {
-		return fmt.Errorf("user %s already exists", cmd.args[0])
+	// The very first user administers the instance, others can be promoted later.
+	userCount, err := state.dbQueriesData.CountUsers(context.Background())
+	if err != nil {
+		return fmt.Errorf("cannot count users: %v", err)
+	}
+
+	apiKey, apiKeyHash, err := generateAPIKey()
+	if err != nil {
+		return err
 	}
 
 	user, err := state.dbQueriesData.CreateUser(context.Background(), database.CreateUserParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name:      cmd.args[0],
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		Name:       username,
+		IsAdmin:    userCount == 0,
+		ApiKeyHash: apiKeyHash,
 	})
 	if err != nil {
 		return fmt.Errorf("cannot create user: %v", err)
 	}
 
-	if err := state.configData.SetUser(cmd.args[0]); err != nil {
+	if err := state.configData.SetCredentials(username, apiKey); err != nil {
 		return err
 	}
 
 	fmt.Printf("User success fully created and registered:\n id: %v, name: %v\n", user.ID, user.Name)
+	if user.IsAdmin {
+		fmt.Println("First user registered, granted admin rights")
+	}
+	fmt.Printf("API key (stored in your config, shown only once): %s\n", apiKey)
+	return nil
+}
+
+/*
+*
+This method replaces the API key of the logged in user, the previous key stops working immediately.
+*/
+func handlerAPIKey(state *state, cmd command, user database.User) error {
+	apiKey, apiKeyHash, err := generateAPIKey()
+	if err != nil {
+		return err
+	}
+
+	err = state.dbQueriesData.SetUserApiKeyHash(context.Background(), database.SetUserApiKeyHashParams{
+		ApiKeyHash: apiKeyHash,
+		UpdatedAt:  time.Now(),
+		ID:         user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error on handler apikey rotate: %v", err)
+	}
+
+	if err := state.configData.SetCredentials(user.Name, apiKey); err != nil {
+		return err
+	}
+
+	fmt.Printf("New API key for %s (stored in your config, shown only once): %s\n", user.Name, apiKey)
+	return nil
+}
+
+func handlerPromote(state *state, cmd command, user database.User) error {
+	if !user.IsAdmin {
+		return errors.New("only admins can promote users")
+	}
+
+	username := cmd.value("username")
+	target, err := state.dbQueriesData.GetUser(context.Background(), username)
+	if err != nil {
+		return fmt.Errorf("user %s does not exists", username)
+	}
+
+	err = state.dbQueriesData.SetUserAdmin(context.Background(), database.SetUserAdminParams{
+		IsAdmin:   true,
+		UpdatedAt: time.Now(),
+		ID:        target.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error on handler promote: %v", err)
+	}
+
+	fmt.Printf("User %s is now an admin\n", target.Name)
 	return nil
 }
 
 func handlerDelete(state *state, cmd command) error {
+	// In strict mode wiping every user is an admin action, like promote.
+	if state.configData.Strict {
+		return middlewareLoggedIn(handlerAdminDelete)(state, cmd)
+	}
+	return deleteUsers(state)
+}
+
+func handlerAdminDelete(state *state, cmd command, user database.User) error {
+	if !user.IsAdmin {
+		return errors.New("strict mode: only admins can reset users")
+	}
+	return deleteUsers(state)
+}
+
+func deleteUsers(state *state) error {
 	err := state.dbQueriesData.DeleteUsers(context.Background())
 	if err != nil {
 		return err
@@ -74,10 +161,14 @@ func handlerGetUsers(state *state, cmd command) error {
 	}
 
 	for _, user := range users {
+		name := user.Name
+		if user.IsAdmin {
+			name += " [admin]"
+		}
 		if user.Name == state.configData.CurrentUser {
-			fmt.Printf("* %s (current)\n", user.Name)
+			fmt.Printf("* %s (current)\n", name)
 		} else {
-			fmt.Printf("* %s\n", user.Name)
+			fmt.Printf("* %s\n", name)
 		}
 	}
 
@@ -86,108 +177,205 @@ func handlerGetUsers(state *state, cmd command) error {
 
 const hardCodedUrl = "https://www.wagslane.dev/index.xml"
 
+const defaultAggConcurrency = 1
+
 func handlerAggCommand(state *state, cmd command) error {
-	if len(cmd.args) < 1 {
-		return errors.New("agg command needs time between request")
+	timeBetweenRequests := cmd.durationValue("time_between_reqs")
+	if timeBetweenRequests <= 0 {
+		return errors.New("error on handler agg: time between requests must be positive")
 	}
 
-	timeParam := cmd.args[0]
-	timeBetweenRequests, err := time.ParseDuration(timeParam)
+	concurrency := cmd.intValue("concurrency")
+	if concurrency < 1 {
+		return fmt.Errorf("error on handler agg: concurrency must be a positive number, got %d", concurrency)
+	}
+
+	batchSize := concurrency
+	if cmd.isSet("batch_size") {
+		batchSize = cmd.intValue("batch_size")
+		if batchSize < 1 {
+			return fmt.Errorf("error on handler agg: batch size must be a positive number, got %d", batchSize)
+		}
+	}
+
+	// Ctrl+C or a SIGTERM cancels ctx, in-flight fetches are aborted but their writes finish.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	ticker := time.NewTicker(timeBetweenRequests)
+	defer ticker.Stop()
 
-	// channel, blocked until got the c channel emit the item
-	for ; ; <-ticker.C {
-		fmt.Printf("Collecting feeds every 1m0s")
+	startedAt := time.Now()
+	stats := &aggStats{}
+	defer func() {
+		fmt.Printf("Aggregator stopped after %s: %s\n", time.Since(startedAt).Round(time.Second), stats)
+	}()
 
-		err = scrapeFeeds(state)
+	retention := state.retention
+	if cmd.isSet("--prune-every") {
+		retention.pruneInterval = cmd.durationValue("--prune-every")
+		if retention.pruneInterval <= 0 {
+			return errors.New("error on handler agg: --prune-every must be positive")
+		}
+	}
+	if retention.pruneInterval > 0 {
+		if retention.keepsEverything() {
+			return errors.New("error on handler agg: pruning needs retention.max_age or retention.max_posts_per_feed in the config")
+		}
+		pruneDone := make(chan struct{})
+		go func() {
+			defer close(pruneDone)
+			runPruneJob(ctx, state.dbQueriesData, retention, stats)
+		}()
+		// runs before the stats are printed, so they include the last prune
+		defer func() {
+			stop()
+			<-pruneDone
+		}()
+		fmt.Printf("Pruning posts every %s\n", retention.pruneInterval)
+	}
+
+	fmt.Printf("Collecting up to %d feeds every %s with %d worker(s)\n", batchSize, timeBetweenRequests, concurrency)
+	for {
+		err := scrapeFeeds(ctx, newStateScraper(state), scrapeOptions{
+			staleAfter:  timeBetweenRequests,
+			batchSize:   batchSize,
+			concurrency: concurrency,
+		}, stats)
+		if ctx.Err() != nil {
+			fmt.Println("Shutting down aggregator")
+			return nil
+		}
 		if err != nil {
 			return err
 		}
+
+		// channel, blocked until got the c channel emit the item or we are asked to stop
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			fmt.Println("Shutting down aggregator")
+			return nil
+		}
 	}
 }
 
-func scrapeFeeds(state *state) error {
-	nextFeed, err := state.dbQueriesData.GetNextFeedToFetched(context.Background())
-	if err != nil {
-		return fmt.Errorf("error when scrape feed when get next feed %v", err)
+/*
+*
+This method applies the retention of the config once, --max-age and --max-posts replace its values.
+*/
+func handlerPrune(state *state, cmd command, user database.User) error {
+	if !user.IsAdmin {
+		return errors.New("only admins can prune posts")
 	}
 
-	err = state.dbQueriesData.MarkFeedFetched(context.Background(), database.MarkFeedFetchedParams{
-		LastFetchedAt: sql.NullTime{
-			Time:  time.Now(),
-			Valid: true, // true means the value is not NULL
-		},
-		Url: nextFeed.Url,
-	})
-	if err != nil {
-		return fmt.Errorf("error when scrape feed on mark feed fetched %v", err)
+	retention := state.retention
+	if cmd.isSet("--max-age") {
+		retention.maxAge = cmd.durationValue("--max-age")
+		if retention.maxAge <= 0 {
+			return errors.New("--max-age must be positive")
+		}
 	}
-
-	feeds, err := fetchFeed(context.Background(), nextFeed.Url)
-	if err != nil {
-		return fmt.Errorf("error when scrape feed on fetch feed fetched %v", err)
+	if cmd.isSet("--max-posts") {
+		retention.maxPostsPerFeed = int64(cmd.intValue("--max-posts"))
+		if retention.maxPostsPerFeed < 1 {
+			return errors.New("--max-posts must be a positive number")
+		}
 	}
 
-	fmt.Printf("Title for feed %s\n", feeds.Channel.Title)
-	for idx, item := range feeds.Channel.Item {
-		fmt.Printf("title: %s\n", item.Title)
-
-		publishedTime, err := time.Parse(time.RFC1123Z, item.PubDate)
-		if err != nil {
-			return fmt.Errorf("error when scrape feed on published time  %v", err)
-		}
-
-		err = state.dbQueriesData.CreatePost(context.Background(), database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-			Title:       item.Title,
-			Url:         item.Link,
-			Description: item.Description,
-			PublishedAt: publishedTime,
-			FeedID:      nextFeed.ID,
-		})
-		if err != nil {
-			return fmt.Errorf("error when scrape feed on create post index %d: %v", idx, err)
-		}
+	result, err := prunePosts(context.Background(), state.dbQueriesData, retention, time.Now())
+	if err != nil {
+		return fmt.Errorf("error on handler prune: %v", err)
 	}
 
+	fmt.Printf("Pruned posts: %s\n", result)
 	return nil
 }
 
+/*
+*
+This method test fetches the url (searching a website for its feeds), names the feed after its title when
+no name is given, then stores it with its channel metadata and imports its current items right away.
+*/
 func handlerAddFeed(state *state, cmd command, user database.User) error {
-	if len(cmd.args) < 2 {
-		return errors.New("feed name and url argument is required")
+	pageUrl, feedName := cmd.value("url"), cmd.value("name")
+	// addfeed used to take the name first, keep accepting that order
+	if !isHTTPUrl(pageUrl) && isHTTPUrl(feedName) {
+		pageUrl, feedName = feedName, pageUrl
 	}
 
-	user, err := state.dbQueriesData.GetUser(context.Background(), state.configData.CurrentUser)
+	candidate, err := findFeed(state, pageUrl)
 	if err != nil {
-		return fmt.Errorf("error on handler add feed get user: %v", err)
+		return fmt.Errorf("error on handler add feed: %v", err)
+	}
+	if feedName == "" {
+		feedName = strings.TrimSpace(candidate.title())
+		if feedName == "" {
+			return fmt.Errorf("feed %s has no title, give it a name: gator addfeed %s <name>", candidate.Url, candidate.Url)
+		}
 	}
-
-	feedName := cmd.args[0]
-	feedUrl := cmd.args[1]
 
 	feed, err := state.dbQueriesData.CreateFeed(context.Background(), database.CreateFeedParams{
-		ID:     uuid.New(),
-		Name:   feedName,
-		Url:    feedUrl,
-		UserID: user.ID,
+		ID:            uuid.New(),
+		Name:          feedName,
+		Url:           candidate.Url,
+		LastFetchedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID:        user.ID,
 	})
 	if err != nil {
 		return fmt.Errorf("error on handler add feed: %v", err)
 	}
 
-	err = handlerFollow(state, command{command: "follow", args: []string{feedUrl}}, user)
+	feedFollow, err := followFeed(state, user, feed, "")
 	if err != nil {
 		return fmt.Errorf("error on handler add feed when follow: %v", err)
 	}
 
+	fmt.Printf("Feed %s successfully followed by %s\n", feedFollow.FeedName, feedFollow.UserName)
 	fmt.Printf("Feed %s and url: %s successfully added\n", feed.Name, feed.Url)
 
+	stored, err := storeFeedItems(context.Background(), state.dbQueriesData, state.retention, feed, candidate.Result)
+	if err != nil {
+		return fmt.Errorf("error on handler add feed when import posts: %v", err)
+	}
+	fmt.Printf("Imported %d post(s) from %s\n", stored.created, feed.Name)
+	stored.printFailures(feed.Url)
+
 	return nil
 }
 
+/*
+*
+This method turns the url given to addfeed into a feed url, a website is searched for its feeds.
+When it has several, the user picks one on a terminal, otherwise the first one is used.
+*/
+func findFeed(state *state, pageUrl string) (feedCandidate, error) {
+	candidates, err := state.feedClient.discoverFeeds(context.Background(), pageUrl)
+	if err != nil {
+		return feedCandidate{}, err
+	}
+	if len(candidates) == 0 {
+		return feedCandidate{}, fmt.Errorf("no rss, atom or json feed found at %s", pageUrl)
+	}
+
+	candidate := candidates[0]
+	if len(candidates) > 1 {
+		if isTerminal(os.Stdin) {
+			candidate, err = chooseFeedCandidate(candidates, os.Stdin, os.Stdout)
+			if err != nil {
+				return feedCandidate{}, err
+			}
+		} else {
+			fmt.Printf("Found %d feeds at %s, using the first one\n", len(candidates), pageUrl)
+		}
+	}
+
+	if candidate.Url != pageUrl {
+		fmt.Printf("Found feed %s at %s\n", candidate.title(), candidate.Url)
+	}
+	return candidate, nil
+}
+
 func handlerFeeds(state *state, cmd command) error {
 	feeds, err := state.dbQueriesData.GetFeeds(context.Background())
 	if err != nil {
@@ -199,34 +387,289 @@ func handlerFeeds(state *state, cmd command) error {
 			return fmt.Errorf("error on handler feeds: %v", err)
 		}
 		fmt.Printf("Feed Title: %s\nFeed Url: %s\n", feed.Name, feed.Url)
+		if feed.Link != "" {
+			fmt.Printf("Website: %s\n", feed.Link)
+		}
 		fmt.Printf("Created by %s\n", user.Name)
+		fmt.Printf("Status: %s\n", feedHealth(feed))
 	}
 
 	return nil
 }
 
-func handlerFollow(state *state, cmd command, user database.User) error {
-	if len(cmd.args) != 1 {
-		return errors.New("url argument is required")
+func feedHealth(feed database.Feed) string {
+	switch {
+	// removefeed --posts archive
+	case feed.Archived:
+		return "archived, not fetched anymore"
+	case feed.Disabled:
+		return fmt.Sprintf("disabled after %d consecutive failures (last error: %s)", feed.ConsecutiveFailures, feed.LastError)
+	case feed.ConsecutiveFailures > 0:
+		return fmt.Sprintf("failing, %d consecutive failures, next try after %s (last error: %s)",
+			feed.ConsecutiveFailures, feed.NextFetchAt.Time.Format(time.RFC1123), feed.LastError)
+	case !feed.LastFetchedAt.Valid:
+		return "never fetched"
+	default:
+		return fmt.Sprintf("ok, last fetched %s", feed.LastFetchedAt.Time.Format(time.RFC1123))
 	}
+}
 
-	user, err := state.dbQueriesData.GetUser(context.Background(), state.configData.CurrentUser)
+func handlerEnableFeed(state *state, cmd command, user database.User) error {
+	feed, err := ownedFeed(context.Background(), state.dbQueriesData, user, cmd.value("url"))
 	if err != nil {
-		return fmt.Errorf("error on handler follow get user: %v", err)
+		return fmt.Errorf("error on handler enable feed: %v", err)
+	}
+	if feed.Archived {
+		return fmt.Errorf("feed %s is archived, it is not fetched anymore", feed.Url)
 	}
 
-	feed, err := state.dbQueriesData.GetFeedByUrl(context.Background(), cmd.args[0])
+	if err := state.dbQueriesData.EnableFeed(context.Background(), feed.ID); err != nil {
+		return fmt.Errorf("error on handler enable feed: %v", err)
+	}
+
+	fmt.Printf("Feed %s enabled again\n", feed.Url)
+	return nil
+}
+
+const (
+	deletePostsPolicy  = "delete"
+	movePostsPolicy    = "move"
+	archivePostsPolicy = "archive"
+)
+
+var removeFeedPolicies = []string{deletePostsPolicy, movePostsPolicy, archivePostsPolicy}
+
+/*
+feedAdminStore holds the queries behind removefeed, the handler passes the queries of its transaction.
+*/
+type feedAdminStore interface {
+	GetFeedByUrl(ctx context.Context, url string) (database.Feed, error)
+	DeleteFeedFollows(ctx context.Context, feedID uuid.UUID) error
+	ArchiveFeed(ctx context.Context, id uuid.UUID) error
+	MovePostsToFeed(ctx context.Context, arg database.MovePostsToFeedParams) error
+	DeletePostsOfFeed(ctx context.Context, feedID uuid.UUID) (int64, error)
+	CountPostsOfFeed(ctx context.Context, feedID uuid.UUID) (int64, error)
+	DeleteFeed(ctx context.Context, id uuid.UUID) error
+}
+
+var errFeedNotOwned = errors.New("only its creator can change it")
+
+/*
+*
+Feeds can only be changed by the user who added them (feeds.user_id).
+*/
+func ownedFeed(ctx context.Context, store feedAdminStore, user database.User, feedUrl string) (database.Feed, error) {
+	feed, err := store.GetFeedByUrl(ctx, feedUrl)
+	if errors.Is(err, sql.ErrNoRows) {
+		return feed, fmt.Errorf("feed %s does not exist", feedUrl)
+	}
 	if err != nil {
-		return fmt.Errorf("error on handler follow get feed by url: %v", err)
+		return feed, err
+	}
+	if feed.UserID != user.ID {
+		return feed, fmt.Errorf("feed %s was added by another user, %w", feedUrl, errFeedNotOwned)
 	}
+	return feed, nil
+}
 
-	feedFollow, err := state.dbQueriesData.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		FeedID:    feed.ID,
-		UserID:    user.ID,
+// what removeFeed did with the posts of the feed
+type feedRemoval struct {
+	deletedPosts int64
+	// posts someone saved, they keep the feed around (archived) as they reference it
+	savedPosts int64
+}
+
+/*
+*
+This method applies a --posts policy of removefeed. Deleting skips the posts someone saved, the feed is then
+archived instead of deleted so those posts stay readable.
+*/
+func removeFeed(ctx context.Context, store feedAdminStore, feed database.Feed, policy string, target database.Feed) (feedRemoval, error) {
+	var removal feedRemoval
+	switch policy {
+	case archivePostsPolicy:
+		return removal, archiveFeed(ctx, store, feed)
+	case movePostsPolicy:
+		err := store.MovePostsToFeed(ctx, database.MovePostsToFeedParams{
+			ToFeedID:   target.ID,
+			FromFeedID: feed.ID,
+		})
+		if err != nil {
+			return removal, fmt.Errorf("move posts: %v", err)
+		}
+		return removal, store.DeleteFeed(ctx, feed.ID)
+	default:
+		deleted, err := store.DeletePostsOfFeed(ctx, feed.ID)
+		if err != nil {
+			return removal, fmt.Errorf("delete posts: %v", err)
+		}
+		removal.deletedPosts = deleted
+
+		// only saved posts are left, deleting the feed would cascade to them
+		removal.savedPosts, err = store.CountPostsOfFeed(ctx, feed.ID)
+		if err != nil {
+			return removal, fmt.Errorf("count saved posts: %v", err)
+		}
+		if removal.savedPosts > 0 {
+			return removal, archiveFeed(ctx, store, feed)
+		}
+		return removal, store.DeleteFeed(ctx, feed.ID)
+	}
+}
+
+func archiveFeed(ctx context.Context, store feedAdminStore, feed database.Feed) error {
+	if err := store.DeleteFeedFollows(ctx, feed.ID); err != nil {
+		return fmt.Errorf("delete follows: %v", err)
+	}
+	if err := store.ArchiveFeed(ctx, feed.ID); err != nil {
+		return fmt.Errorf("archive: %v", err)
+	}
+	return nil
+}
+
+/*
+*
+This method removes a feed and every follow of it, its posts are deleted (except the saved ones), moved to the
+feed given with --to, or kept with --posts archive: the feed row stays, archived and followed by nobody.
+*/
+func handlerRemoveFeed(state *state, cmd command, user database.User) error {
+	ctx := context.Background()
+	feed, err := ownedFeed(ctx, state.dbQueriesData, user, cmd.value("url"))
+	if err != nil {
+		return fmt.Errorf("error on handler remove feed: %v", err)
+	}
+
+	policy := cmd.value("--posts")
+	if cmd.isSet("--to") != (policy == movePostsPolicy) {
+		return errors.New("--to is required by --posts move and only used by it")
+	}
+	var target database.Feed
+	if policy == movePostsPolicy {
+		target, err = state.dbQueriesData.GetFeedByUrl(ctx, cmd.value("--to"))
+		if err != nil {
+			return fmt.Errorf("error on handler remove feed get feed %s: %v", cmd.value("--to"), err)
+		}
+		if target.ID == feed.ID {
+			return errors.New("cannot move the posts of a feed to itself")
+		}
+	}
+
+	tx, err := state.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on handler remove feed begin: %v", err)
+	}
+	defer tx.Rollback()
+
+	removal, err := removeFeed(ctx, state.dbQueriesData.WithTx(tx), feed, policy, target)
+	if err != nil {
+		return fmt.Errorf("error on handler remove feed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error on handler remove feed commit: %v", err)
+	}
+
+	switch policy {
+	case archivePostsPolicy:
+		fmt.Printf("Feed %s archived, its posts are kept\n", feed.Url)
+	case movePostsPolicy:
+		fmt.Printf("Feed %s removed, its posts moved to %s\n", feed.Url, target.Name)
+	default:
+		fmt.Printf("Deleted %d post(s) of %s\n", removal.deletedPosts, feed.Name)
+		if removal.savedPosts > 0 {
+			fmt.Printf("Feed %s archived, %d saved post(s) still reference it\n", feed.Url, removal.savedPosts)
+		} else {
+			fmt.Printf("Feed %s removed\n", feed.Url)
+		}
+	}
+	return nil
+}
+
+func handlerRenameFeed(state *state, cmd command, user database.User) error {
+	feed, err := ownedFeed(context.Background(), state.dbQueriesData, user, cmd.value("url"))
+	if err != nil {
+		return fmt.Errorf("error on handler rename feed: %v", err)
+	}
+	name := strings.TrimSpace(cmd.value("name"))
+	if name == "" {
+		return errors.New("the name of a feed cannot be empty")
+	}
+
+	err = state.dbQueriesData.RenameFeed(context.Background(), database.RenameFeedParams{
+		Name: name,
+		ID:   feed.ID,
 	})
+	if err != nil {
+		return fmt.Errorf("error on handler rename feed: %v", err)
+	}
+
+	fmt.Printf("Feed %s renamed from %s to %s\n", feed.Url, feed.Name, name)
+	return nil
+}
+
+/*
+*
+This method points a feed at a new url once a test fetch shows it parses, the cache validators and the failure
+count of the old url are reset so the next agg fetches it fully.
+*/
+func handlerSetFeedUrl(state *state, cmd command, user database.User) error {
+	ctx := context.Background()
+	feed, err := ownedFeed(ctx, state.dbQueriesData, user, cmd.value("url"))
+	if err != nil {
+		return fmt.Errorf("error on handler set feed url: %v", err)
+	}
+	if feed.Archived {
+		return fmt.Errorf("feed %s is archived, it is not fetched anymore", feed.Url)
+	}
+
+	result, err := state.feedClient.fetchFeed(ctx, cmd.value("new_url"), feedCache{})
+	if err != nil {
+		return fmt.Errorf("error on handler set feed url fetch %s: %v", cmd.value("new_url"), err)
+	}
+	newUrl := movedUrl(cmd.value("new_url"), result)
+	if newUrl == feed.Url {
+		return fmt.Errorf("feed %s already uses this url", feed.Name)
+	}
+
+	existing, err := state.dbQueriesData.GetFeedByUrl(ctx, newUrl)
+	if err == nil {
+		return fmt.Errorf("feed %s already uses %s, remove this one with: gator removefeed %s --posts move --to %s",
+			existing.Name, newUrl, feed.Url, newUrl)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error on handler set feed url get feed by url: %v", err)
+	}
+
+	tx, err := state.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on handler set feed url begin: %v", err)
+	}
+	defer tx.Rollback()
+	queries := state.dbQueriesData.WithTx(tx)
+
+	if err := queries.UpdateFeedUrl(ctx, database.UpdateFeedUrlParams{Url: newUrl, ID: feed.ID}); err != nil {
+		return fmt.Errorf("error on handler set feed url: %v", err)
+	}
+	if err := queries.UpdateFeedCache(ctx, database.UpdateFeedCacheParams{ID: feed.ID}); err != nil {
+		return fmt.Errorf("error on handler set feed url reset cache: %v", err)
+	}
+	if err := queries.EnableFeed(ctx, feed.ID); err != nil {
+		return fmt.Errorf("error on handler set feed url reset health: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error on handler set feed url commit: %v", err)
+	}
+
+	fmt.Printf("Feed %s now fetched from %s\n", feed.Name, newUrl)
+	return nil
+}
+
+func handlerFollow(state *state, cmd command, user database.User) error {
+	feed, err := state.dbQueriesData.GetFeedByUrl(context.Background(), cmd.value("url"))
+	if err != nil {
+		return fmt.Errorf("error on handler follow get feed by url: %v", err)
+	}
+
+	feedFollow, err := followFeed(state, user, feed, "")
 	if err != nil {
 		return fmt.Errorf("error on handler follow create feed follow: %v", err)
 	}
@@ -236,12 +679,21 @@ func handlerFollow(state *state, cmd command, user database.User) error {
 	return nil
 }
 
-func handlerFollowing(state *state, cmd command, user database.User) error {
-	user, err := state.dbQueriesData.GetUser(context.Background(), state.configData.CurrentUser)
-	if err != nil {
-		return fmt.Errorf("error on handler following get user: %v", err)
+func followFeed(state *state, user database.User, feed database.Feed, category string) (database.CreateFeedFollowRow, error) {
+	if feed.Archived {
+		return database.CreateFeedFollowRow{}, fmt.Errorf("feed %s is archived, it is not fetched anymore", feed.Url)
 	}
+	return state.dbQueriesData.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		FeedID:    feed.ID,
+		UserID:    user.ID,
+		Category:  category,
+	})
+}
 
+func handlerFollowing(state *state, cmd command, user database.User) error {
 	feedFollows, err := state.dbQueriesData.GetFeedFollowsForUser(context.Background(), user.ID)
 	if err != nil {
 		return fmt.Errorf("error on handler following get feed follows for user: %v", err)
@@ -250,6 +702,9 @@ func handlerFollowing(state *state, cmd command, user database.User) error {
 	fmt.Println("Feed followed by user:")
 	for idx, feedFollow := range feedFollows {
 		fmt.Printf("%d Feed Title: %s\nFeed Url: %s\n", idx+1, feedFollow.FeedName, feedFollow.FeedUrl)
+		if feedFollow.Category != "" {
+			fmt.Printf("Category: %s\n", feedFollow.Category)
+		}
 	}
 
 	return nil
@@ -262,16 +717,18 @@ func middlewareLoggedIn(handler func(s *state, cmd command, user database.User)
 			return fmt.Errorf("error on handler following get user: %v", err)
 		}
 
+		if s.configData.Strict {
+			if err := checkAPIKey(user, s.configData.APIKey); err != nil {
+				return fmt.Errorf("strict mode: %v", err)
+			}
+		}
+
 		return handler(s, c, user)
 	}
 }
 
 func handlerUnFollow(state *state, cmd command, user database.User) error {
-	if len(cmd.args) != 1 {
-		return errors.New("url argument is required")
-	}
-
-	feed, err := state.dbQueriesData.GetFeedByUrl(context.Background(), cmd.args[0])
+	feed, err := state.dbQueriesData.GetFeedByUrl(context.Background(), cmd.value("url"))
 	if err != nil {
 		return fmt.Errorf("error on handler unfollow get feed by url: %v", err)
 	}
@@ -287,27 +744,413 @@ func handlerUnFollow(state *state, cmd command, user database.User) error {
 	return nil
 }
 
-func handlerBrowse(state *state, cmd command) error {
-	var limit int
-	if len(cmd.args) < 1 {
-		limit = 2
-	} else {
-		converted, err := strconv.Atoi(cmd.args[0])
-		limit = converted
+func handlerBrowse(state *state, cmd command, user database.User) error {
+	opts, err := browseOptionsFromCommand(cmd, time.Now())
+	if err != nil {
+		return fmt.Errorf("error on handler browse: %v", err)
+	}
+	if opts.allFeeds && !user.IsAdmin {
+		return errors.New("only admins can browse every feed with --all")
+	}
+
+	posts, err := browsePosts(context.Background(), state.dbQueriesData, user, opts)
+	if err != nil {
+		return fmt.Errorf("error on handler browse on get post: %v", err)
+	}
+
+	for _, item := range posts {
+		readMarker := "*"
+		if item.IsRead {
+			readMarker = " "
+		}
+		fmt.Printf("%s The title of the post %s\n", readMarker, item.Title)
+		fmt.Printf("  Feed: %s\n", item.FeedName)
+		fmt.Printf("  Published at %s\n", item.PublishedAt)
+		fmt.Printf("  Id: %s\n", item.ID)
+	}
+
+	if len(posts) == opts.limit {
+		last := posts[len(posts)-1]
+		next := browseCursor{PublishedAt: last.PublishedAt, ID: last.ID}
+		fmt.Printf("More posts: repeat with --cursor %s\n", next.encode())
+	}
+
+	return nil
+}
+
+// the queries lookupPost needs, *database.Queries implements it
+type postLookupStore interface {
+	GetPostById(ctx context.Context, id uuid.UUID) (database.GetPostByIdRow, error)
+	GetPostByUrl(ctx context.Context, url string) (database.GetPostByUrlRow, error)
+}
+
+/*
+*
+Posts can be referenced by the id printed by browse or by their url.
+*/
+func lookupPost(ctx context.Context, store postLookupStore, ref string) (database.GetPostByIdRow, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return store.GetPostById(ctx, id)
+	}
+	post, err := store.GetPostByUrl(ctx, ref)
+	return database.GetPostByIdRow(post), err
+}
+
+func handlerRead(state *state, cmd command, user database.User) error {
+	post, err := lookupPost(context.Background(), state.dbQueriesData, cmd.value("post"))
+	if err != nil {
+		return fmt.Errorf("error on handler read get post: %v", err)
+	}
+
+	err = state.dbQueriesData.MarkPostRead(context.Background(), database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+		ReadAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error on handler read mark post read: %v", err)
+	}
+
+	fmt.Printf("Post %s marked as read\n", post.Title)
+	return nil
+}
+
+func handlerUnread(state *state, cmd command, user database.User) error {
+	post, err := lookupPost(context.Background(), state.dbQueriesData, cmd.value("post"))
+	if err != nil {
+		return fmt.Errorf("error on handler unread get post: %v", err)
+	}
+
+	err = state.dbQueriesData.MarkPostUnread(context.Background(), database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error on handler unread mark post unread: %v", err)
+	}
+
+	fmt.Printf("Post %s marked as unread\n", post.Title)
+	return nil
+}
+
+// the queries markAllRead needs, *database.Queries implements it
+type markReadStore interface {
+	GetFeedByUrl(ctx context.Context, url string) (database.Feed, error)
+	MarkAllPostsRead(ctx context.Context, arg database.MarkAllPostsReadParams) (int64, error)
+}
+
+/*
+*
+This method marks the posts of the followed feeds as read, only those of feedUrl when it is not empty.
+*/
+func markAllRead(ctx context.Context, store markReadStore, user database.User, feedUrl string, now time.Time) (int64, error) {
+	var feedID uuid.NullUUID
+	if feedUrl != "" {
+		feed, err := store.GetFeedByUrl(ctx, feedUrl)
 		if err != nil {
-			return fmt.Errorf("error on handler browse on convert: %v", err)
+			return 0, fmt.Errorf("cannot find feed %s: %v", feedUrl, err)
 		}
+		feedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
 	}
 
-	posts, err := state.dbQueriesData.GetPosts(context.Background(), int32(limit))
+	return store.MarkAllPostsRead(ctx, database.MarkAllPostsReadParams{
+		UserID: user.ID,
+		ReadAt: now,
+		FeedID: feedID,
+	})
+}
+
+func handlerMarkAllRead(state *state, cmd command, user database.User) error {
+	marked, err := markAllRead(context.Background(), state.dbQueriesData, user, cmd.value("feed_url"), time.Now())
 	if err != nil {
-		return fmt.Errorf("error on handler browse on get post: %v", err)
+		return fmt.Errorf("error on handler mark all read: %v", err)
+	}
+
+	fmt.Printf("%d post(s) marked as read\n", marked)
+	return nil
+}
+
+const defaultSearchLimit = 10
+
+func handlerSearch(state *state, cmd command, user database.User) error {
+	limit := cmd.intValue("--limit")
+	if limit < 1 {
+		return errors.New("limit must be a positive number")
+	}
+
+	var feedID uuid.NullUUID
+	if cmd.isSet("--feed") {
+		feed, err := state.dbQueriesData.GetFeedByUrl(context.Background(), cmd.value("--feed"))
+		if err != nil {
+			return fmt.Errorf("error on handler search get feed by url: %v", err)
+		}
+		feedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+
+	query, err := buildTsQuery(strings.Join(cmd.list("query"), " "))
+	if err != nil {
+		return err
+	}
+
+	posts, err := state.dbQueriesData.SearchPosts(context.Background(), database.SearchPostsParams{
+		Query:        query,
+		FeedID:       feedID,
+		OnlyFollowed: cmd.boolValue("--following"),
+		UserID:       user.ID,
+		PostLimit:    int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("error on handler search: %v", err)
+	}
+
+	if len(posts) == 0 {
+		fmt.Println("No post found")
+		return nil
+	}
+	for _, item := range posts {
+		fmt.Printf("%s (%s)\n", item.Title, item.FeedName)
+		fmt.Printf("  Published at %s\n", item.PublishedAt)
+		fmt.Printf("  Url: %s\n", item.Url)
+		fmt.Printf("  Id: %s\n", item.ID)
+	}
+
+	return nil
+}
+
+// the queries behind save, unsave and saved, *database.Queries implements it
+type savedPostStore interface {
+	postLookupStore
+	SavePost(ctx context.Context, arg database.SavePostParams) error
+	UnsavePost(ctx context.Context, arg database.UnsavePostParams) error
+	GetSavedPosts(ctx context.Context, arg database.GetSavedPostsParams) ([]database.GetSavedPostsRow, error)
+}
+
+/*
+*
+This method saves the post referenced by ref, tags are lowercased and added to those it already has.
+*/
+func savePost(ctx context.Context, store savedPostStore, user database.User, ref string, tags []string, now time.Time) (database.GetPostByIdRow, error) {
+	post, err := lookupPost(ctx, store, ref)
+	if err != nil {
+		return post, fmt.Errorf("cannot find post %s: %v", ref, err)
+	}
+
+	// never nil, a NULL array would violate the not null constraint
+	cleanTags := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" {
+			cleanTags = append(cleanTags, tag)
+		}
+	}
+
+	return post, store.SavePost(ctx, database.SavePostParams{
+		UserID:    user.ID,
+		PostID:    post.ID,
+		Tags:      cleanTags,
+		CreatedAt: now,
+	})
+}
+
+func unsavePost(ctx context.Context, store savedPostStore, user database.User, ref string) (database.GetPostByIdRow, error) {
+	post, err := lookupPost(ctx, store, ref)
+	if err != nil {
+		return post, fmt.Errorf("cannot find post %s: %v", ref, err)
+	}
+
+	return post, store.UnsavePost(ctx, database.UnsavePostParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+}
+
+/*
+*
+This method lists the saved posts of the user, only those tagged with tag when it is set.
+*/
+func savedPosts(ctx context.Context, store savedPostStore, user database.User, tag *string) ([]database.GetSavedPostsRow, error) {
+	params := database.GetSavedPostsParams{UserID: user.ID}
+	if tag != nil {
+		params.Tag = sql.NullString{String: strings.ToLower(*tag), Valid: true}
+	}
+	return store.GetSavedPosts(ctx, params)
+}
+
+func handlerSave(state *state, cmd command, user database.User) error {
+	post, err := savePost(context.Background(), state.dbQueriesData, user, cmd.value("post"), cmd.list("tags"), time.Now())
+	if err != nil {
+		return fmt.Errorf("error on handler save: %v", err)
+	}
+
+	fmt.Printf("Post %s saved\n", post.Title)
+	return nil
+}
+
+func handlerUnsave(state *state, cmd command, user database.User) error {
+	post, err := unsavePost(context.Background(), state.dbQueriesData, user, cmd.value("post"))
+	if err != nil {
+		return fmt.Errorf("error on handler unsave: %v", err)
+	}
+
+	fmt.Printf("Post %s removed from saved posts\n", post.Title)
+	return nil
+}
+
+func handlerSaved(state *state, cmd command, user database.User) error {
+	var tag *string
+	if cmd.isSet("--tag") {
+		value := cmd.value("--tag")
+		tag = &value
+	}
+
+	posts, err := savedPosts(context.Background(), state.dbQueriesData, user, tag)
+	if err != nil {
+		return fmt.Errorf("error on handler saved: %v", err)
 	}
 
+	if len(posts) == 0 {
+		fmt.Println("No saved post")
+		return nil
+	}
 	for _, item := range posts {
-		fmt.Printf("The title of the post %s\n", item.Title)
-		fmt.Printf("Published at %s\n", item.PublishedAt)
+		fmt.Printf("%s\n", item.Title)
+		fmt.Printf("  Url: %s\n", item.Url)
+		if len(item.Tags) > 0 {
+			fmt.Printf("  Tags: %s\n", strings.Join(item.Tags, ", "))
+		}
+		fmt.Printf("  Saved at %s\n", item.SavedAt)
+		fmt.Printf("  Id: %s\n", item.ID)
+	}
+
+	return nil
+}
+
+func handlerImport(state *state, cmd command, user database.User) error {
+	file, err := os.Open(cmd.value("file"))
+	if err != nil {
+		return fmt.Errorf("error on handler import open file: %v", err)
 	}
+	defer file.Close()
 
+	subscriptions, err := readOPML(file)
+	if err != nil {
+		return fmt.Errorf("error on handler import: %v", err)
+	}
+
+	feedFollows, err := state.dbQueriesData.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error on handler import get feed follows for user: %v", err)
+	}
+	following := make(map[string]bool, len(feedFollows))
+	for _, feedFollow := range feedFollows {
+		following[feedFollow.FeedUrl] = true
+	}
+
+	var created, followed, skipped, archived int
+	for _, subscription := range subscriptions {
+		feed, err := state.dbQueriesData.GetFeedByUrl(context.Background(), subscription.Url)
+		if errors.Is(err, sql.ErrNoRows) {
+			name := subscription.Title
+			if name == "" {
+				name = subscription.Url
+			}
+			feed, err = state.dbQueriesData.CreateFeed(context.Background(), database.CreateFeedParams{
+				ID:     uuid.New(),
+				Name:   name,
+				Url:    subscription.Url,
+				UserID: user.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("error on handler import create feed %s: %v", subscription.Url, err)
+			}
+			created++
+		} else if err != nil {
+			return fmt.Errorf("error on handler import get feed by url %s: %v", subscription.Url, err)
+		}
+
+		if following[feed.Url] {
+			skipped++
+			continue
+		}
+		if feed.Archived {
+			archived++
+			continue
+		}
+
+		_, err = followFeed(state, user, feed, subscription.Category)
+		if err != nil {
+			return fmt.Errorf("error on handler import follow %s: %v", subscription.Url, err)
+		}
+		following[feed.Url] = true
+		followed++
+	}
+
+	fmt.Printf("Imported %d subscription(s): %d new feed(s), %d followed, %d already followed, %d archived\n",
+		len(subscriptions), created, followed, skipped, archived)
+	return nil
+}
+
+func handlerExport(state *state, cmd command, user database.User) error {
+	feedFollows, err := state.dbQueriesData.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error on handler export get feed follows for user: %v", err)
+	}
+
+	subscriptions := make([]opmlSubscription, 0, len(feedFollows))
+	for _, feedFollow := range feedFollows {
+		subscriptions = append(subscriptions, opmlSubscription{
+			Title:    feedFollow.FeedName,
+			Url:      feedFollow.FeedUrl,
+			Category: feedFollow.Category,
+		})
+	}
+
+	output := os.Stdout
+	if cmd.isSet("file") {
+		output, err = os.Create(cmd.value("file"))
+		if err != nil {
+			return fmt.Errorf("error on handler export create file: %v", err)
+		}
+		defer output.Close()
+	}
+
+	title := fmt.Sprintf("%s subscriptions in gator", user.Name)
+	if err := writeOPML(output, title, subscriptions, time.Now()); err != nil {
+		return fmt.Errorf("error on handler export: %v", err)
+	}
+
+	if cmd.isSet("file") {
+		fmt.Printf("Exported %d feed(s) to %s\n", len(subscriptions), cmd.value("file"))
+	}
+	return nil
+}
+
+func handlerOutput(state *state, cmd command, user database.User) error {
+	format := cmd.value("--format")
+	category := cmd.value("--category")
+	baseUrl := cmd.value("--base-url")
+
+	feed, err := buildOutputFeed(context.Background(), state.dbQueriesData, user, category, cmd.intValue("--limit"),
+		outputSiteUrl(baseUrl), outputFeedUrl(baseUrl, format, category))
+	if err != nil {
+		return fmt.Errorf("error on handler output: %v", err)
+	}
+
+	output := os.Stdout
+	if cmd.isSet("file") {
+		output, err = os.Create(cmd.value("file"))
+		if err != nil {
+			return fmt.Errorf("error on handler output create file: %v", err)
+		}
+		defer output.Close()
+	}
+
+	if err := writeOutputFeed(output, format, feed, user.Name, time.Now()); err != nil {
+		return fmt.Errorf("error on handler output: %v", err)
+	}
+
+	if cmd.isSet("file") {
+		fmt.Printf("Wrote %d post(s) to %s\n", len(feed.Channel.Item), cmd.value("file"))
+	}
 	return nil
 }
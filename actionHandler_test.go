@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFeedHealth(t *testing.T) {
+	fetchedAt := sql.NullTime{Time: time.Date(2025, 2, 11, 9, 0, 0, 0, time.UTC), Valid: true}
+	expected := map[string]database.Feed{
+		"never fetched":                 {},
+		"ok, last fetched":              {LastFetchedAt: fetchedAt},
+		"failing, 2 consecutive":        {LastFetchedAt: fetchedAt, ConsecutiveFailures: 2},
+		"disabled after 10":             {Disabled: true, ConsecutiveFailures: 10},
+		"archived, not fetched anymore": {Archived: true, Disabled: true},
+	}
+
+	for want, feed := range expected {
+		if got := feedHealth(feed); !strings.HasPrefix(got, want) {
+			t.Errorf("expected health starting with '%s', got: '%s'", want, got)
+		}
+	}
+}
+
+func TestRemoveFeedSpec_Policies(t *testing.T) {
+	spec := testCommandSpec(t, "removefeed")
+
+	cmd, err := parseCommandArgs(spec, []string{"https://example.com/feed.xml"})
+	if err != nil {
+		t.Fatalf("parseCommandArgs() returned unexpected error: %v", err)
+	}
+	if cmd.value("--posts") != deletePostsPolicy {
+		t.Errorf("expected posts to be deleted by default, got: %s", cmd.value("--posts"))
+	}
+
+	cmd, err = parseCommandArgs(spec, []string{"https://example.com/feed.xml", "--posts", "move", "--to", "https://example.com/new.xml"})
+	if err != nil || cmd.value("--to") != "https://example.com/new.xml" {
+		t.Errorf("expected the move policy with its target, got: %v %v", cmd.values, err)
+	}
+
+	if _, err := parseCommandArgs(spec, []string{"https://example.com/feed.xml", "--posts=keep"}); err == nil {
+		t.Errorf("expected an error for an unknown policy")
+	}
+}
+
+// records the removefeed queries in the order they ran
+type fakeFeedAdminStore struct {
+	feeds map[string]database.Feed
+	calls []string
+	// posts of the feed left by DeletePostsOfFeed, the saved ones
+	savedPosts int64
+}
+
+func (f *fakeFeedAdminStore) GetFeedByUrl(ctx context.Context, url string) (database.Feed, error) {
+	feed, ok := f.feeds[url]
+	if !ok {
+		return feed, sql.ErrNoRows
+	}
+	return feed, nil
+}
+
+func (f *fakeFeedAdminStore) DeleteFeedFollows(ctx context.Context, feedID uuid.UUID) error {
+	f.calls = append(f.calls, "DeleteFeedFollows")
+	return nil
+}
+
+func (f *fakeFeedAdminStore) ArchiveFeed(ctx context.Context, id uuid.UUID) error {
+	f.calls = append(f.calls, "ArchiveFeed")
+	return nil
+}
+
+func (f *fakeFeedAdminStore) MovePostsToFeed(ctx context.Context, arg database.MovePostsToFeedParams) error {
+	f.calls = append(f.calls, "MovePostsToFeed "+arg.FromFeedID.String()+" "+arg.ToFeedID.String())
+	return nil
+}
+
+func (f *fakeFeedAdminStore) DeletePostsOfFeed(ctx context.Context, feedID uuid.UUID) (int64, error) {
+	f.calls = append(f.calls, "DeletePostsOfFeed")
+	return 4, nil
+}
+
+func (f *fakeFeedAdminStore) CountPostsOfFeed(ctx context.Context, feedID uuid.UUID) (int64, error) {
+	f.calls = append(f.calls, "CountPostsOfFeed")
+	return f.savedPosts, nil
+}
+
+func (f *fakeFeedAdminStore) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	f.calls = append(f.calls, "DeleteFeed")
+	return nil
+}
+
+func TestOwnedFeed(t *testing.T) {
+	creator := database.User{ID: uuid.New()}
+	other := database.User{ID: uuid.New()}
+	admin := database.User{ID: uuid.New(), IsAdmin: true}
+	store := &fakeFeedAdminStore{feeds: map[string]database.Feed{
+		"https://example.com/feed.xml": {ID: uuid.New(), UserID: creator.ID},
+	}}
+	ctx := context.Background()
+
+	if _, err := ownedFeed(ctx, store, creator, "https://example.com/feed.xml"); err != nil {
+		t.Errorf("expected the creator to own the feed, got: %v", err)
+	}
+	if _, err := ownedFeed(ctx, store, other, "https://example.com/feed.xml"); err == nil {
+		t.Errorf("expected an error for another user")
+	}
+	if _, err := ownedFeed(ctx, store, admin, "https://example.com/feed.xml"); err == nil {
+		t.Errorf("expected an error for an admin, only the creator changes a feed")
+	}
+	if _, err := ownedFeed(ctx, store, creator, "https://example.com/missing.xml"); err == nil {
+		t.Errorf("expected an error for an unknown feed")
+	}
+}
+
+func TestRemoveFeed_Policies(t *testing.T) {
+	feed := database.Feed{ID: uuid.New()}
+	target := database.Feed{ID: uuid.New()}
+	expected := map[string][]string{
+		deletePostsPolicy:  {"DeletePostsOfFeed", "CountPostsOfFeed", "DeleteFeed"},
+		movePostsPolicy:    {"MovePostsToFeed " + feed.ID.String() + " " + target.ID.String(), "DeleteFeed"},
+		archivePostsPolicy: {"DeleteFeedFollows", "ArchiveFeed"},
+	}
+
+	for policy, want := range expected {
+		store := &fakeFeedAdminStore{}
+		removal, err := removeFeed(context.Background(), store, feed, policy, target)
+		if err != nil {
+			t.Fatalf("removeFeed(%s) returned unexpected error: %v", policy, err)
+		}
+		if !slices.Equal(store.calls, want) {
+			t.Errorf("removeFeed(%s) expected queries %v, got: %v", policy, want, store.calls)
+		}
+		if policy == deletePostsPolicy && removal.deletedPosts != 4 {
+			t.Errorf("expected 4 deleted posts, got: %d", removal.deletedPosts)
+		}
+	}
+}
+
+func TestRemoveFeed_KeepsSavedPosts(t *testing.T) {
+	feed := database.Feed{ID: uuid.New()}
+	store := &fakeFeedAdminStore{savedPosts: 2}
+
+	removal, err := removeFeed(context.Background(), store, feed, deletePostsPolicy, database.Feed{})
+	if err != nil {
+		t.Fatalf("removeFeed() returned unexpected error: %v", err)
+	}
+	expected := []string{"DeletePostsOfFeed", "CountPostsOfFeed", "DeleteFeedFollows", "ArchiveFeed"}
+	if !slices.Equal(store.calls, expected) {
+		t.Errorf("expected the feed to be archived for its saved posts %v, got: %v", expected, store.calls)
+	}
+	if removal.deletedPosts != 4 || removal.savedPosts != 2 {
+		t.Errorf("expected 4 deleted and 2 saved posts, got: %+v", removal)
+	}
+}
+
+// keeps the saved posts of every user, tags merged like the upsert of SavePost
+type fakeSavedPostStore struct {
+	posts []database.GetPostByIdRow
+	saved map[database.UnsavePostParams][]string
+}
+
+func (f *fakeSavedPostStore) GetPostById(ctx context.Context, id uuid.UUID) (database.GetPostByIdRow, error) {
+	for _, post := range f.posts {
+		if post.ID == id {
+			return post, nil
+		}
+	}
+	return database.GetPostByIdRow{}, sql.ErrNoRows
+}
+
+func (f *fakeSavedPostStore) GetPostByUrl(ctx context.Context, url string) (database.GetPostByUrlRow, error) {
+	for _, post := range f.posts {
+		if post.Url == url {
+			return database.GetPostByUrlRow(post), nil
+		}
+	}
+	return database.GetPostByUrlRow{}, sql.ErrNoRows
+}
+
+func (f *fakeSavedPostStore) SavePost(ctx context.Context, arg database.SavePostParams) error {
+	if arg.Tags == nil {
+		return errors.New("null value in column tags violates not-null constraint")
+	}
+	key := database.UnsavePostParams{UserID: arg.UserID, PostID: arg.PostID}
+	tags := slices.Concat(f.saved[key], arg.Tags)
+	slices.Sort(tags)
+	f.saved[key] = slices.Compact(tags)
+	return nil
+}
+
+func (f *fakeSavedPostStore) UnsavePost(ctx context.Context, arg database.UnsavePostParams) error {
+	delete(f.saved, arg)
+	return nil
+}
+
+func (f *fakeSavedPostStore) GetSavedPosts(ctx context.Context, arg database.GetSavedPostsParams) ([]database.GetSavedPostsRow, error) {
+	var rows []database.GetSavedPostsRow
+	for _, post := range f.posts {
+		tags, ok := f.saved[database.UnsavePostParams{UserID: arg.UserID, PostID: post.ID}]
+		if !ok || (arg.Tag.Valid && !slices.Contains(tags, arg.Tag.String)) {
+			continue
+		}
+		rows = append(rows, database.GetSavedPostsRow{ID: post.ID, Title: post.Title, Url: post.Url, Tags: tags})
+	}
+	return rows, nil
+}
+
+func savedTitles(t *testing.T, store savedPostStore, user database.User, tag *string) []string {
+	t.Helper()
+	posts, err := savedPosts(context.Background(), store, user, tag)
+	if err != nil {
+		t.Fatalf("savedPosts() returned unexpected error: %v", err)
+	}
+	var titles []string
+	for _, post := range posts {
+		titles = append(titles, post.Title)
+	}
+	return titles
+}
+
+func TestSavedPosts_SaveUnsaveAndTags(t *testing.T) {
+	goPost := database.GetPostByIdRow{ID: uuid.New(), Title: "Go 1.24", Url: "https://go.dev/blog/go1.24"}
+	rustPost := database.GetPostByIdRow{ID: uuid.New(), Title: "Rust 1.85", Url: "https://blog.rust-lang.org/1.85"}
+	store := &fakeSavedPostStore{posts: []database.GetPostByIdRow{goPost, rustPost}, saved: make(map[database.UnsavePostParams][]string)}
+	alice, bob := database.User{ID: uuid.New()}, database.User{ID: uuid.New()}
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := savePost(ctx, store, alice, goPost.ID.String(), []string{" Go ", "release", ""}, now); err != nil {
+		t.Fatalf("savePost() returned unexpected error: %v", err)
+	}
+	if _, err := savePost(ctx, store, alice, goPost.Url, []string{"lang"}, now); err != nil {
+		t.Fatalf("savePost() by url returned unexpected error: %v", err)
+	}
+	if _, err := savePost(ctx, store, alice, rustPost.Url, nil, now); err != nil {
+		t.Fatalf("savePost() without tags returned unexpected error: %v", err)
+	}
+	if _, err := savePost(ctx, store, alice, "https://missing.example.com", nil, now); err == nil {
+		t.Error("expected error when saving an unknown post, got nil")
+	}
+
+	key := database.UnsavePostParams{UserID: alice.ID, PostID: goPost.ID}
+	if tags := store.saved[key]; !slices.Equal(tags, []string{"go", "lang", "release"}) {
+		t.Errorf("expected the tags lowercased, trimmed and merged, got: %v", tags)
+	}
+	if got := savedTitles(t, store, alice, nil); !slices.Equal(got, []string{"Go 1.24", "Rust 1.85"}) {
+		t.Errorf("expected both saved posts, got: %v", got)
+	}
+	tag := "GO"
+	if got := savedTitles(t, store, alice, &tag); !slices.Equal(got, []string{"Go 1.24"}) {
+		t.Errorf("expected only the posts tagged go, got: %v", got)
+	}
+	if got := savedTitles(t, store, bob, nil); len(got) != 0 {
+		t.Errorf("expected the saved posts of alice to stay hers, got: %v", got)
+	}
+
+	if _, err := unsavePost(ctx, store, alice, goPost.ID.String()); err != nil {
+		t.Fatalf("unsavePost() returned unexpected error: %v", err)
+	}
+	if got := savedTitles(t, store, alice, nil); !slices.Equal(got, []string{"Rust 1.85"}) {
+		t.Errorf("expected the unsaved post gone, got: %v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// makes keys easy to spot in configs and logs
+const apiKeyPrefix = "gator_"
+
+var errInvalidAPIKey = errors.New("invalid api key")
+
+/*
+*
+This method returns a new random key and the hash stored in users.api_key_hash, the key itself is never stored.
+*/
+func generateAPIKey() (string, sql.NullString, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", sql.NullString{}, fmt.Errorf("cannot generate api key: %v", err)
+	}
+
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+	return key, hashAPIKey(key), nil
+}
+
+// keys have 256 random bits, a fast unsalted hash is enough to make a leaked users table useless
+func hashAPIKey(key string) sql.NullString {
+	sum := sha256.Sum256([]byte(key))
+	return sql.NullString{String: hex.EncodeToString(sum[:]), Valid: true}
+}
+
+func checkAPIKey(user database.User, key string) error {
+	if !user.ApiKeyHash.Valid {
+		return fmt.Errorf("user %s has no api key yet, run apikey rotate with strict mode off", user.Name)
+	}
+	if key == "" {
+		return fmt.Errorf("no api key configured for %s, run login %s --api-key <key>", user.Name, user.Name)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key).String), []byte(user.ApiKeyHash.String)) != 1 {
+		return errInvalidAPIKey
+	}
+	return nil
+}
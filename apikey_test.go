@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, hash, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("generateAPIKey() returned unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(key, apiKeyPrefix) || len(key) != len(apiKeyPrefix)+64 {
+		t.Errorf("expected a prefixed key of 64 hex characters, got: %s", key)
+	}
+	if !hash.Valid || hash.String == key || hash != hashAPIKey(key) {
+		t.Errorf("expected the hash of the key, got: %+v", hash)
+	}
+
+	other, _, _ := generateAPIKey()
+	if other == key {
+		t.Errorf("expected two different keys, got: %s twice", key)
+	}
+}
+
+func TestCheckAPIKey(t *testing.T) {
+	key, hash, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("generateAPIKey() returned unexpected error: %v", err)
+	}
+	user := database.User{Name: "alice", ApiKeyHash: hash}
+
+	if err := checkAPIKey(user, key); err != nil {
+		t.Errorf("expected the key to match, got: %v", err)
+	}
+	if err := checkAPIKey(user, key+"0"); !errors.Is(err, errInvalidAPIKey) {
+		t.Errorf("expected errInvalidAPIKey for a wrong key, got: %v", err)
+	}
+	if err := checkAPIKey(user, ""); err == nil || !strings.Contains(err.Error(), "login alice --api-key") {
+		t.Errorf("expected a hint to configure the key, got: %v", err)
+	}
+	if err := checkAPIKey(database.User{Name: "bob"}, key); err == nil || !strings.Contains(err.Error(), "apikey rotate") {
+		t.Errorf("expected a hint to rotate the key, got: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"cmp"
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+type AtomFeed struct {
+	XMLName  xml.Name    `xml:"feed"`
+	Xmlns    string      `xml:"xmlns,attr,omitempty"`
+	ID       string      `xml:"id,omitempty"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated,omitempty"`
+	Author   *AtomPerson `xml:"author,omitempty"`
+	Logo     string      `xml:"logo,omitempty"`
+	Icon     string      `xml:"icon,omitempty"`
+	Links    []AtomLink  `xml:"link"`
+	Entries  []AtomEntry `xml:"entry"`
+}
+
+type AtomEntry struct {
+	ID        string      `xml:"id,omitempty"`
+	Title     string      `xml:"title"`
+	Links     []AtomLink  `xml:"link"`
+	Summary   *AtomText   `xml:"summary,omitempty"`
+	Content   *AtomText   `xml:"content,omitempty"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published,omitempty"`
+	Source    *AtomSource `xml:"source,omitempty"`
+}
+
+/*
+Text and html content are escaped character data, xhtml content is markup nested in a div, so it is kept raw.
+*/
+type AtomText struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t *AtomText) String() string {
+	if t == nil {
+		return ""
+	}
+	if t.Type != "xhtml" {
+		return strings.TrimSpace(t.Text)
+	}
+	markup := strings.TrimSpace(t.Inner)
+	// drop the wrapping <div xmlns="http://www.w3.org/1999/xhtml">
+	if strings.HasPrefix(markup, "<div") && strings.HasSuffix(markup, "</div>") {
+		if start := strings.Index(markup, ">"); start >= 0 {
+			markup = strings.TrimSpace(markup[start+1 : len(markup)-len("</div>")])
+		}
+	}
+	return markup
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type AtomPerson struct {
+	Name string `xml:"name"`
+}
+
+type AtomSource struct {
+	Title string     `xml:"title"`
+	Links []AtomLink `xml:"link"`
+}
+
+/*
+*
+Atom allows several links per element, the one without rel (or with rel="alternate") points to the html page.
+*/
+func alternateLink(links []AtomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+// the rel="self" link, where the document itself is served
+func selfLink(links []AtomLink) string {
+	for _, link := range links {
+		if link.Rel == "self" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+/*
+*
+This method maps an Atom document onto the RSS model so the rest of the app only deals with one shape.
+*/
+func (a *AtomFeed) toRSSFeed() *RSSFeed {
+	var feed RSSFeed
+	feed.Channel.Title = a.Title
+	feed.Channel.Link = alternateLink(a.Links)
+	feed.Channel.Description = a.Subtitle
+	// the logo is the larger picture, the icon is meant for favicons
+	if image := cmp.Or(a.Logo, a.Icon); image != "" {
+		feed.Channel.Image = &RSSImage{Url: image, Title: a.Title, Link: feed.Channel.Link}
+	}
+
+	for _, entry := range a.Entries {
+		description := cmp.Or(entry.Summary.String(), entry.Content.String())
+		pubDate := entry.Published
+		if pubDate == "" {
+			pubDate = entry.Updated
+		}
+		// Atom dates are RFC3339, keep them in the same layout as the RSS pubDate.
+		if parsed, err := time.Parse(time.RFC3339, pubDate); err == nil {
+			pubDate = parsed.Format(time.RFC1123Z)
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       entry.Title,
+			Link:        alternateLink(entry.Links),
+			Description: description,
+			PubDate:     pubDate,
+		})
+	}
+
+	return &feed
+}
+
+/*
+*
+This method is the reverse of toRSSFeed, used to serve the output feed as Atom. Atom needs an id and an updated
+date on every entry, they come from the guid (or link) and the pubDate, the newest entry dates the feed.
+*/
+func (f *RSSFeed) toAtomFeed(author string, now time.Time) *AtomFeed {
+	self := selfLink(f.Channel.AtomLinks)
+	atom := AtomFeed{
+		Xmlns:    atomNamespace,
+		ID:       cmp.Or(self, f.Channel.Link),
+		Title:    f.Channel.Title,
+		Subtitle: f.Channel.Description,
+		Author:   &AtomPerson{Name: author},
+	}
+	if self != "" {
+		atom.Links = append(atom.Links, AtomLink{Href: self, Rel: "self", Type: "application/atom+xml"})
+	}
+	if f.Channel.Link != "" {
+		atom.Links = append(atom.Links, AtomLink{Href: f.Channel.Link, Rel: "alternate"})
+	}
+
+	updated := time.Time{}
+	for _, item := range f.Channel.Item {
+		published := now
+		if parsed, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
+			published = parsed
+		}
+		if published.After(updated) {
+			updated = published
+		}
+
+		id := item.GUID
+		if id == "" {
+			id = item.Link
+		}
+		entry := AtomEntry{
+			ID:        id,
+			Title:     item.Title,
+			Links:     []AtomLink{{Href: item.Link, Rel: "alternate"}},
+			Summary:   &AtomText{Type: "html", Text: item.Description},
+			Updated:   published.Format(time.RFC3339),
+			Published: published.Format(time.RFC3339),
+		}
+		if item.Source != nil {
+			entry.Source = &AtomSource{Title: item.Source.Name, Links: []AtomLink{{Href: item.Source.Url, Rel: "self"}}}
+		}
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	if updated.IsZero() {
+		updated = now
+	}
+	atom.Updated = updated.Format(time.RFC3339)
+	return &atom
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultBrowseLimit = 2
+
+// shared by the browse command and the posts endpoint of the api
+var browseFlags = []flagSpec{
+	{name: "limit", kind: intValue, defaultValue: strconv.Itoa(defaultBrowseLimit), description: "number of posts"},
+	{name: "page", kind: intValue, defaultValue: "1", description: "page to show, starting at 1"},
+	{name: "cursor", placeholder: "cursor", description: "continue after the cursor printed by a full page"},
+	{name: "feed", placeholder: "url", description: "only posts of this feed"},
+	{name: "since", placeholder: "time", description: "posts published after a date or a duration ago, e.g. 48h"},
+	{name: "until", placeholder: "time", description: "posts published before a date or a duration ago"},
+	{name: "sort", choices: []string{"newest", "oldest"}, defaultValue: "newest", description: "sort by publication date"},
+	{name: "include-read", kind: boolValue, description: "include posts you already read"},
+	{name: "all", kind: boolValue, description: "posts of every feed, not only the followed ones (admins only)"},
+}
+
+type browseOptions struct {
+	limit       int
+	page        int
+	cursor      *browseCursor
+	feedUrl     string
+	since       time.Time
+	until       time.Time
+	oldestFirst bool
+	includeRead bool
+	allFeeds    bool
+}
+
+/*
+The position of the last post of a page, the next page starts right after it in (published_at, id) order.
+*/
+type browseCursor struct {
+	PublishedAt time.Time
+	ID          uuid.UUID
+}
+
+func (c browseCursor) encode() string {
+	raw := fmt.Sprintf("%d:%s", c.PublishedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeBrowseCursor(value string) (*browseCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %v", err)
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, errors.New("invalid cursor")
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %v", err)
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %v", err)
+	}
+
+	// published_at is a timestamp without time zone, keep the cursor in UTC like the driver does
+	return &browseCursor{PublishedAt: time.Unix(0, unixNano).UTC(), ID: parsedID}, nil
+}
+
+/*
+*
+Both absolute dates (any layout parsePubDate knows, e.g. 2024-01-31) and durations relative to now (e.g. 48h) are accepted.
+*/
+func parseBrowseTime(value string, now time.Time) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	return parsePubDate(value)
+}
+
+/*
+*
+This method turns the parsed browse arguments into query options, the positional limit wins over --limit.
+*/
+func browseOptionsFromCommand(cmd command, now time.Time) (browseOptions, error) {
+	opts := browseOptions{
+		limit:       cmd.intValue("--limit"),
+		page:        cmd.intValue("--page"),
+		feedUrl:     cmd.value("--feed"),
+		oldestFirst: cmd.value("--sort") == "oldest",
+		includeRead: cmd.boolValue("--include-read"),
+		allFeeds:    cmd.boolValue("--all"),
+	}
+	if cmd.isSet("limit") {
+		opts.limit = cmd.intValue("limit")
+	}
+
+	var err error
+	if cmd.isSet("--cursor") {
+		if opts.cursor, err = decodeBrowseCursor(cmd.value("--cursor")); err != nil {
+			return opts, err
+		}
+	}
+	if cmd.isSet("--since") {
+		if opts.since, err = parseBrowseTime(cmd.value("--since"), now); err != nil {
+			return opts, fmt.Errorf("invalid --since: %v", err)
+		}
+	}
+	if cmd.isSet("--until") {
+		if opts.until, err = parseBrowseTime(cmd.value("--until"), now); err != nil {
+			return opts, fmt.Errorf("invalid --until: %v", err)
+		}
+	}
+
+	if opts.limit < 1 {
+		return opts, errors.New("limit must be a positive number")
+	}
+	if opts.page < 1 {
+		return opts, errors.New("--page starts at 1")
+	}
+	if opts.cursor != nil && cmd.isSet("--page") {
+		return opts, errors.New("--page and --cursor cannot be used together")
+	}
+	return opts, nil
+}
+
+// the queries browsePosts needs, *database.Queries implements it
+type browseStore interface {
+	GetFeedByUrl(ctx context.Context, url string) (database.Feed, error)
+	BrowsePostsNewestFirst(ctx context.Context, arg database.BrowsePostsNewestFirstParams) ([]database.BrowsePostsNewestFirstRow, error)
+	BrowsePostsOldestFirst(ctx context.Context, arg database.BrowsePostsOldestFirstParams) ([]database.BrowsePostsOldestFirstRow, error)
+}
+
+/*
+*
+This method runs the browse query matching the sort direction, rows always come back as BrowsePostsNewestFirstRow.
+*/
+func browsePosts(ctx context.Context, store browseStore, user database.User, opts browseOptions) ([]database.BrowsePostsNewestFirstRow, error) {
+	params := database.BrowsePostsNewestFirstParams{
+		UserID:      user.ID,
+		AllFeeds:    opts.allFeeds,
+		IncludeRead: opts.includeRead,
+		PostLimit:   int32(opts.limit),
+		PostOffset:  int32((opts.page - 1) * opts.limit),
+	}
+	if opts.feedUrl != "" {
+		feed, err := store.GetFeedByUrl(ctx, opts.feedUrl)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find feed %s: %v", opts.feedUrl, err)
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	if !opts.since.IsZero() {
+		params.Since = sql.NullTime{Time: opts.since, Valid: true}
+	}
+	if !opts.until.IsZero() {
+		params.Until = sql.NullTime{Time: opts.until, Valid: true}
+	}
+	if opts.cursor != nil {
+		params.CursorPublishedAt = sql.NullTime{Time: opts.cursor.PublishedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: opts.cursor.ID, Valid: true}
+	}
+
+	if !opts.oldestFirst {
+		return store.BrowsePostsNewestFirst(ctx, params)
+	}
+
+	rows, err := store.BrowsePostsOldestFirst(ctx, database.BrowsePostsOldestFirstParams(params))
+	if err != nil {
+		return nil, err
+	}
+	posts := make([]database.BrowsePostsNewestFirstRow, 0, len(rows))
+	for _, row := range rows {
+		posts = append(posts, database.BrowsePostsNewestFirstRow(row))
+	}
+	return posts, nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBrowseCursor_RoundTrip(t *testing.T) {
+	cursor := browseCursor{
+		PublishedAt: time.Date(2024, time.January, 31, 8, 30, 0, 123000, time.UTC),
+		ID:          uuid.New(),
+	}
+
+	decoded, err := decodeBrowseCursor(cursor.encode())
+	if err != nil {
+		t.Fatalf("decodeBrowseCursor() returned unexpected error: %v", err)
+	}
+	if !decoded.PublishedAt.Equal(cursor.PublishedAt) || decoded.ID != cursor.ID {
+		t.Errorf("expected cursor %+v, got: %+v", cursor, *decoded)
+	}
+}
+
+func TestDecodeBrowseCursor_Invalid(t *testing.T) {
+	for _, value := range []string{"", "not base64!", "bm8gY29sb24"} {
+		if _, err := decodeBrowseCursor(value); err == nil {
+			t.Errorf("decodeBrowseCursor(%q) expected error, got nil", value)
+		}
+	}
+}
+
+func parseBrowseCommand(t *testing.T, args []string, now time.Time) (browseOptions, error) {
+	t.Helper()
+	cmd, err := parseCommandArgs(testCommandSpec(t, "browse"), args)
+	if err != nil {
+		return browseOptions{}, err
+	}
+	return browseOptionsFromCommand(cmd, now)
+}
+
+func TestBrowseOptions_Defaults(t *testing.T) {
+	opts, err := parseBrowseCommand(t, nil, time.Now())
+	if err != nil {
+		t.Fatalf("browseOptionsFromCommand() returned unexpected error: %v", err)
+	}
+	if opts.limit != defaultBrowseLimit || opts.page != 1 || opts.oldestFirst || opts.includeRead || opts.allFeeds {
+		t.Errorf("unexpected default options: %+v", opts)
+	}
+}
+
+func TestBrowseOptions_Flags(t *testing.T) {
+	now := time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)
+	args := []string{"--feed", "https://example.com/feed.xml", "10", "--since", "48h", "--until=2024-01-31",
+		"--page", "3", "--sort", "oldest", "--include-read"}
+
+	opts, err := parseBrowseCommand(t, args, now)
+	if err != nil {
+		t.Fatalf("browseOptionsFromCommand() returned unexpected error: %v", err)
+	}
+
+	if opts.limit != 10 {
+		t.Errorf("expected limit 10, got: %d", opts.limit)
+	}
+	if opts.page != 3 {
+		t.Errorf("expected page 3, got: %d", opts.page)
+	}
+	if opts.feedUrl != "https://example.com/feed.xml" {
+		t.Errorf("expected feed url, got: %s", opts.feedUrl)
+	}
+	if !opts.since.Equal(now.Add(-48 * time.Hour)) {
+		t.Errorf("expected since 48h before now, got: %v", opts.since)
+	}
+	if !opts.until.Equal(time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected until 2024-01-31, got: %v", opts.until)
+	}
+	if !opts.oldestFirst || !opts.includeRead {
+		t.Errorf("expected oldest first and include read, got: %+v", opts)
+	}
+}
+
+func TestBrowseOptions_Invalid(t *testing.T) {
+	invalid := [][]string{
+		{"--page"},
+		{"--page", "0"},
+		{"--sort", "sideways"},
+		{"--unknown", "x"},
+		{"zero"},
+		{"--limit", "0"},
+		{"--since", "yesterday-ish"},
+		{"--page", "2", "--cursor", browseCursor{ID: uuid.New()}.encode()},
+	}
+
+	for _, args := range invalid {
+		if _, err := parseBrowseCommand(t, args, time.Now()); err == nil {
+			t.Errorf("browse %v expected error, got nil", args)
+		}
+	}
+}
+
+/*
+fakeReaderStore plays the browse and post_reads queries of a single user over posts kept newest first.
+*/
+type fakeReaderStore struct {
+	feeds    map[string]database.Feed
+	followed map[uuid.UUID]bool
+	posts    []database.BrowsePostsNewestFirstRow
+	read     map[uuid.UUID]bool
+}
+
+func newFakeReaderStore() *fakeReaderStore {
+	return &fakeReaderStore{
+		feeds:    make(map[string]database.Feed),
+		followed: make(map[uuid.UUID]bool),
+		read:     make(map[uuid.UUID]bool),
+	}
+}
+
+func (f *fakeReaderStore) addFeed(url string, followed bool, titles ...string) database.Feed {
+	feed := database.Feed{ID: uuid.New(), Name: url, Url: url}
+	f.feeds[url] = feed
+	f.followed[feed.ID] = followed
+	for _, title := range titles {
+		f.posts = append(f.posts, database.BrowsePostsNewestFirstRow{ID: uuid.New(), Title: title, FeedID: feed.ID, FeedName: feed.Name})
+	}
+	return feed
+}
+
+func (f *fakeReaderStore) GetFeedByUrl(ctx context.Context, url string) (database.Feed, error) {
+	feed, ok := f.feeds[url]
+	if !ok {
+		return feed, sql.ErrNoRows
+	}
+	return feed, nil
+}
+
+func (f *fakeReaderStore) browse(posts []database.BrowsePostsNewestFirstRow, arg database.BrowsePostsNewestFirstParams) []database.BrowsePostsNewestFirstRow {
+	var rows []database.BrowsePostsNewestFirstRow
+	for _, post := range posts {
+		if !arg.AllFeeds && !f.followed[post.FeedID] {
+			continue
+		}
+		if !arg.IncludeRead && f.read[post.ID] {
+			continue
+		}
+		if arg.FeedID.Valid && post.FeedID != arg.FeedID.UUID {
+			continue
+		}
+		post.IsRead = f.read[post.ID]
+		rows = append(rows, post)
+	}
+	return rows[min(int(arg.PostOffset), len(rows)):min(int(arg.PostOffset+arg.PostLimit), len(rows))]
+}
+
+func (f *fakeReaderStore) BrowsePostsNewestFirst(ctx context.Context, arg database.BrowsePostsNewestFirstParams) ([]database.BrowsePostsNewestFirstRow, error) {
+	return f.browse(f.posts, arg), nil
+}
+
+func (f *fakeReaderStore) BrowsePostsOldestFirst(ctx context.Context, arg database.BrowsePostsOldestFirstParams) ([]database.BrowsePostsOldestFirstRow, error) {
+	oldestFirst := slices.Clone(f.posts)
+	slices.Reverse(oldestFirst)
+	var rows []database.BrowsePostsOldestFirstRow
+	for _, row := range f.browse(oldestFirst, database.BrowsePostsNewestFirstParams(arg)) {
+		rows = append(rows, database.BrowsePostsOldestFirstRow(row))
+	}
+	return rows, nil
+}
+
+func (f *fakeReaderStore) MarkAllPostsRead(ctx context.Context, arg database.MarkAllPostsReadParams) (int64, error) {
+	var marked int64
+	for _, post := range f.posts {
+		if !f.followed[post.FeedID] || f.read[post.ID] {
+			continue
+		}
+		if arg.FeedID.Valid && post.FeedID != arg.FeedID.UUID {
+			continue
+		}
+		f.read[post.ID] = true
+		marked++
+	}
+	return marked, nil
+}
+
+func browsedTitles(t *testing.T, store browseStore, user database.User, args ...string) []string {
+	t.Helper()
+	opts, err := parseBrowseCommand(t, args, time.Now())
+	if err != nil {
+		t.Fatalf("browseOptionsFromCommand() returned unexpected error: %v", err)
+	}
+	posts, err := browsePosts(context.Background(), store, user, opts)
+	if err != nil {
+		t.Fatalf("browsePosts() returned unexpected error: %v", err)
+	}
+	titles := make([]string, 0, len(posts))
+	for _, post := range posts {
+		titles = append(titles, post.Title)
+	}
+	return titles
+}
+
+func TestBrowsePosts_UnreadByDefault(t *testing.T) {
+	store := newFakeReaderStore()
+	store.addFeed("https://go.dev/blog/feed.atom", true, "Go 1.24", "Go 1.23", "Go 1.22")
+	store.read[store.posts[1].ID] = true
+	user := database.User{ID: uuid.New()}
+
+	if got := browsedTitles(t, store, user, "10"); !slices.Equal(got, []string{"Go 1.24", "Go 1.22"}) {
+		t.Errorf("expected only the unread posts, got: %v", got)
+	}
+	if got := browsedTitles(t, store, user, "10", "--include-read"); !slices.Equal(got, []string{"Go 1.24", "Go 1.23", "Go 1.22"}) {
+		t.Errorf("expected every post with --include-read, got: %v", got)
+	}
+	if got := browsedTitles(t, store, user, "10", "--sort", "oldest"); !slices.Equal(got, []string{"Go 1.22", "Go 1.24"}) {
+		t.Errorf("expected the unread posts oldest first, got: %v", got)
+	}
+}
+
+func TestMarkAllRead(t *testing.T) {
+	store := newFakeReaderStore()
+	goBlog := store.addFeed("https://go.dev/blog/feed.atom", true, "Go 1.24", "Go 1.23")
+	store.addFeed("https://example.com/feed.xml", true, "Example")
+	store.addFeed("https://unfollowed.example.com/feed.xml", false, "Unfollowed")
+	user := database.User{ID: uuid.New()}
+	ctx := context.Background()
+
+	marked, err := markAllRead(ctx, store, user, goBlog.Url, time.Now())
+	if err != nil {
+		t.Fatalf("markAllRead() returned unexpected error: %v", err)
+	}
+	if marked != 2 {
+		t.Errorf("expected 2 posts marked, got: %d", marked)
+	}
+	if got := browsedTitles(t, store, user, "10"); !slices.Equal(got, []string{"Example"}) {
+		t.Errorf("expected only the posts of the other feed left unread, got: %v", got)
+	}
+
+	if _, err := markAllRead(ctx, store, user, "https://missing.example.com/feed.xml", time.Now()); err == nil {
+		t.Error("expected error for an unknown feed, got nil")
+	}
+
+	marked, err = markAllRead(ctx, store, user, "", time.Now())
+	if err != nil {
+		t.Fatalf("markAllRead() returned unexpected error: %v", err)
+	}
+	if marked != 1 {
+		t.Errorf("expected only the remaining followed post marked, got: %d", marked)
+	}
+	if got := browsedTitles(t, store, user, "10"); len(got) != 0 {
+		t.Errorf("expected nothing left unread, got: %v", got)
+	}
+	if store.read[store.posts[3].ID] {
+		t.Error("expected the post of the unfollowed feed to stay unread")
+	}
+}
+
+func TestHandlerBrowse_AllRequiresAdmin(t *testing.T) {
+	cmd, err := parseCommandArgs(testCommandSpec(t, "browse"), []string{"--all"})
+	if err != nil {
+		t.Fatalf("parseCommandArgs() returned unexpected error: %v", err)
+	}
+
+	// the check runs before any query, the state needs no database
+	if err := handlerBrowse(&state{}, cmd, database.User{ID: uuid.New()}); err == nil {
+		t.Error("expected error when a non-admin browses with --all, got nil")
+	}
+}
+
+func TestBrowsePosts_OnlyFollowedFeeds(t *testing.T) {
+	store := newFakeReaderStore()
+	store.addFeed("https://go.dev/blog/feed.atom", true, "Go 1.24")
+	store.addFeed("https://unfollowed.example.com/feed.xml", false, "Unfollowed")
+	user := database.User{ID: uuid.New(), IsAdmin: true}
+
+	if got := browsedTitles(t, store, user, "10"); !slices.Equal(got, []string{"Go 1.24"}) {
+		t.Errorf("expected only the posts of followed feeds, got: %v", got)
+	}
+	if got := browsedTitles(t, store, user, "10", "--all"); !slices.Equal(got, []string{"Go 1.24", "Unfollowed"}) {
+		t.Errorf("expected the posts of every feed with --all, got: %v", got)
+	}
+}
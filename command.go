@@ -3,50 +3,415 @@ package main
 import (
 	"bootDevGoRss/internal/config"
 	"bootDevGoRss/internal/database"
+	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type state struct {
+	db            *sql.DB
 	dbQueriesData *database.Queries
 	configData    *config.Config
+	feedClient    *feedClient
+	retention     retentionPolicy
 }
 
 /*
 For example, in the case of the login command,
-the name would be "login" and the handler will expect the arguments slice to contain one string, the username.
+the name would be "login" and the handler reads the username with cmd.value("username").
+Positional arguments are stored under their name, flags under "--" + their name.
 */
 type command struct {
 	command string
 	args    []string
+	values  map[string][]string
+	// names given on the command line, values missing from here come from the spec defaults
+	provided map[string]bool
 }
 
+type valueKind int
+
+const (
+	stringValue valueKind = iota
+	intValue
+	durationValue
+	boolValue
+)
+
+type argSpec struct {
+	name        string
+	kind        valueKind
+	description string
+	optional    bool
+	// a variadic argument takes every remaining positional argument, it must be the last one
+	variadic     bool
+	defaultValue string
+	choices      []string
+}
+
+type flagSpec struct {
+	name        string
+	kind        valueKind
+	description string
+	// shown in the usage line, e.g. --feed url
+	placeholder  string
+	defaultValue string
+	choices      []string
+}
+
+/*
+A commandSpec declares everything the registry needs to parse, validate and document a command,
+so handlers only deal with already checked values.
+*/
+type commandSpec struct {
+	name        string
+	description string
+	args        []argSpec
+	flags       []flagSpec
+	handler     func(*state, command) error
+}
+
+var errHelpRequested = errors.New("help requested")
+
 type commands struct {
-	// This will be a map of command names to their handler functions.
-	mapper map[string]func(*state, command) error
+	// This will be a map of command names to their specs.
+	mapper map[string]commandSpec
+	// registration order, used to list the commands in help
+	names []string
 }
 
 /*
 *
-This method runs a given command with the provided state if it exists.
+This method parses the arguments of a given command against its spec and runs it with the provided state.
 */
 func (c *commands) run(state *state, cmd command) error {
-	handler, ok := c.mapper[cmd.command]
+	spec, ok := c.mapper[cmd.command]
 	if !ok {
-		return fmt.Errorf("command %s does not exist", cmd.command)
+		return fmt.Errorf("command %s does not exist, run help to list the commands", cmd.command)
 	}
-	return handler(state, cmd)
+
+	parsed, err := parseCommandArgs(spec, cmd.args)
+	if errors.Is(err, errHelpRequested) {
+		fmt.Print(spec.helpText())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%v\nusage: %s", err, spec.usage())
+	}
+	return spec.handler(state, parsed)
 }
 
 /*
 *
-This method registers a new handler function for a command name.
+This method registers a new command spec, it fails on duplicated names or specs the parser could not honour.
 */
-func (c *commands) register(name string, f func(*state, command) error) error {
-	_, ok := c.mapper[name]
+func (c *commands) register(spec commandSpec) error {
+	_, ok := c.mapper[spec.name]
 	if ok {
-		return fmt.Errorf("command %s already exists", name)
+		return fmt.Errorf("command %s already exists", spec.name)
+	}
+	if spec.handler == nil {
+		return fmt.Errorf("command %s has no handler", spec.name)
+	}
+
+	seen := make(map[string]bool)
+	for idx, arg := range spec.args {
+		if seen[arg.name] {
+			return fmt.Errorf("argument %s declared twice", arg.name)
+		}
+		seen[arg.name] = true
+		if arg.variadic && idx != len(spec.args)-1 {
+			return fmt.Errorf("variadic argument %s must be the last one", arg.name)
+		}
+		if !arg.optional && idx > 0 && spec.args[idx-1].optional {
+			return fmt.Errorf("required argument %s cannot follow an optional one", arg.name)
+		}
+	}
+	for _, flag := range spec.flags {
+		if seen["--"+flag.name] || flag.name == "help" {
+			return fmt.Errorf("flag --%s declared twice", flag.name)
+		}
+		seen["--"+flag.name] = true
 	}
 
-	c.mapper[name] = f
+	c.mapper[spec.name] = spec
+	c.names = append(c.names, spec.name)
 	return nil
 }
+
+/*
+*
+Flags start with "--" and may appear anywhere between the positional arguments, as "--name value" or "--name=value".
+Boolean flags take no value, a lone "--" makes every following argument positional.
+*/
+func parseCommandArgs(spec commandSpec, args []string) (command, error) {
+	cmd := command{
+		command:  spec.name,
+		args:     args,
+		values:   make(map[string][]string),
+		provided: make(map[string]bool),
+	}
+
+	var positional []string
+	onlyPositional := false
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		if onlyPositional || !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+		if arg == "--" {
+			onlyPositional = true
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if name == "help" {
+			return cmd, errHelpRequested
+		}
+		flag, ok := spec.flag(name)
+		if !ok {
+			return cmd, fmt.Errorf("unknown flag --%s", name)
+		}
+
+		if flag.kind == boolValue {
+			if !hasValue {
+				value = "true"
+			}
+		} else if !hasValue {
+			if idx+1 >= len(args) {
+				return cmd, fmt.Errorf("--%s needs a value", name)
+			}
+			idx++
+			value = args[idx]
+		}
+
+		value, err := checkValue(flag.kind, flag.choices, value)
+		if err != nil {
+			return cmd, fmt.Errorf("invalid value for --%s: %v", name, err)
+		}
+		cmd.values["--"+name] = []string{value}
+		cmd.provided["--"+name] = true
+	}
+
+	for _, arg := range spec.args {
+		if len(positional) == 0 {
+			if !arg.optional {
+				return cmd, fmt.Errorf("%s argument is required", arg.name)
+			}
+			if arg.defaultValue != "" {
+				cmd.values[arg.name] = []string{arg.defaultValue}
+			}
+			continue
+		}
+
+		taken := positional[:1]
+		if arg.variadic {
+			taken = positional
+		}
+		for idx, value := range taken {
+			checked, err := checkValue(arg.kind, arg.choices, value)
+			if err != nil {
+				return cmd, fmt.Errorf("invalid %s argument: %v", arg.name, err)
+			}
+			taken[idx] = checked
+		}
+		cmd.values[arg.name] = append([]string(nil), taken...)
+		cmd.provided[arg.name] = true
+		positional = positional[len(taken):]
+	}
+	if len(positional) > 0 {
+		return cmd, fmt.Errorf("unexpected argument %s", positional[0])
+	}
+
+	for _, flag := range spec.flags {
+		if !cmd.provided["--"+flag.name] && flag.defaultValue != "" {
+			cmd.values["--"+flag.name] = []string{flag.defaultValue}
+		}
+	}
+	return cmd, nil
+}
+
+/*
+*
+This method validates a raw value against its kind and returns it in canonical form (booleans become "true"/"false").
+*/
+func checkValue(kind valueKind, choices []string, value string) (string, error) {
+	switch kind {
+	case intValue:
+		if _, err := strconv.Atoi(value); err != nil {
+			return value, fmt.Errorf("%s is not a number", value)
+		}
+	case durationValue:
+		if _, err := time.ParseDuration(value); err != nil {
+			return value, fmt.Errorf("%s is not a duration (e.g. 30s, 5m, 1h)", value)
+		}
+	case boolValue:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return value, fmt.Errorf("%s is not true or false", value)
+		}
+		value = strconv.FormatBool(parsed)
+	}
+
+	if len(choices) > 0 && !slices.Contains(choices, value) {
+		return value, fmt.Errorf("must be one of %s, got %s", strings.Join(choices, ", "), value)
+	}
+	return value, nil
+}
+
+func (s commandSpec) flag(name string) (flagSpec, bool) {
+	for _, flag := range s.flags {
+		if flag.name == name {
+			return flag, true
+		}
+	}
+	return flagSpec{}, false
+}
+
+/*
+*
+This method returns the first value of an argument or a flag, or "" when it was not given and has no default.
+*/
+func (c command) value(name string) string {
+	if len(c.values[name]) == 0 {
+		return ""
+	}
+	return c.values[name][0]
+}
+
+// every value of a variadic argument
+func (c command) list(name string) []string {
+	return c.values[name]
+}
+
+// values are validated by parseCommandArgs, the conversions below cannot fail for a declared kind
+func (c command) intValue(name string) int {
+	converted, _ := strconv.Atoi(c.value(name))
+	return converted
+}
+
+func (c command) durationValue(name string) time.Duration {
+	converted, _ := time.ParseDuration(c.value(name))
+	return converted
+}
+
+func (c command) boolValue(name string) bool {
+	return c.value(name) == "true"
+}
+
+// reports whether the argument or flag was given on the command line, defaults do not count
+func (c command) isSet(name string) bool {
+	return c.provided[name]
+}
+
+func (s commandSpec) usage() string {
+	parts := []string{"gator", s.name}
+	for _, arg := range s.args {
+		name := arg.name
+		if arg.variadic {
+			name += "..."
+		}
+		if arg.optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+	for _, flag := range s.flags {
+		parts = append(parts, "["+flag.usage()+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f flagSpec) usage() string {
+	if f.kind == boolValue {
+		return "--" + f.name
+	}
+
+	placeholder := f.placeholder
+	switch {
+	case placeholder != "":
+	case len(f.choices) > 0:
+		placeholder = strings.Join(f.choices, "|")
+	case f.kind == intValue:
+		placeholder = "n"
+	case f.kind == durationValue:
+		placeholder = "duration"
+	default:
+		placeholder = "value"
+	}
+	return "--" + f.name + " " + placeholder
+}
+
+/*
+*
+This method renders the help of a single command: usage line, description, arguments and flags with their defaults.
+*/
+func (s commandSpec) helpText() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "usage: %s\n\n%s\n", s.usage(), s.description)
+
+	if len(s.args) > 0 {
+		builder.WriteString("\nArguments:\n")
+		for _, arg := range s.args {
+			line := arg.description
+			if arg.defaultValue != "" {
+				line += fmt.Sprintf(" (default %s)", arg.defaultValue)
+			}
+			fmt.Fprintf(&builder, "  %-22s %s\n", arg.name, line)
+		}
+	}
+
+	if len(s.flags) > 0 {
+		builder.WriteString("\nFlags:\n")
+		for _, flag := range s.flags {
+			line := flag.description
+			if flag.defaultValue != "" {
+				line += fmt.Sprintf(" (default %s)", flag.defaultValue)
+			}
+			fmt.Fprintf(&builder, "  %-22s %s\n", flag.usage(), line)
+		}
+	}
+	return builder.String()
+}
+
+/*
+*
+This method prints every registered command, or the detailed help of one when its name is given.
+*/
+func (c *commands) handlerHelp(state *state, cmd command) error {
+	if name := cmd.value("command"); name != "" {
+		spec, ok := c.mapper[name]
+		if !ok {
+			return fmt.Errorf("command %s does not exist", name)
+		}
+		fmt.Print(spec.helpText())
+		return nil
+	}
+
+	fmt.Println("usage: gator <command> [args...]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, name := range c.names {
+		fmt.Printf("  %-12s %s\n", name, c.mapper[name].description)
+	}
+	fmt.Println()
+	fmt.Println("Run gator help <command> (or gator <command> --help) for its arguments and flags.")
+	return nil
+}
+
+func (c *commands) helpSpec() commandSpec {
+	return commandSpec{
+		name:        "help",
+		description: "List the commands or show the arguments and flags of one",
+		args: []argSpec{
+			{name: "command", description: "command to describe", optional: true},
+		},
+		handler: c.handlerHelp,
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testCommandSpec(t *testing.T, name string) commandSpec {
+	t.Helper()
+	for _, spec := range commandSpecs() {
+		if spec.name == name {
+			return spec
+		}
+	}
+	t.Fatalf("command %s is not declared", name)
+	return commandSpec{}
+}
+
+func TestCommandSpecs_Register(t *testing.T) {
+	registry := commands{mapper: make(map[string]commandSpec)}
+	for _, spec := range append(commandSpecs(), registry.helpSpec()) {
+		if err := registry.register(spec); err != nil {
+			t.Errorf("register(%s) returned unexpected error: %v", spec.name, err)
+		}
+	}
+
+	if err := registry.register(registry.helpSpec()); err == nil {
+		t.Errorf("registering help twice expected error, got nil")
+	}
+}
+
+func TestRegister_InvalidSpec(t *testing.T) {
+	noop := func(*state, command) error { return nil }
+	invalid := []commandSpec{
+		{name: "nohandler"},
+		{name: "variadic", handler: noop, args: []argSpec{{name: "a", variadic: true}, {name: "b"}}},
+		{name: "order", handler: noop, args: []argSpec{{name: "a", optional: true}, {name: "b"}}},
+		{name: "twice", handler: noop, flags: []flagSpec{{name: "x"}, {name: "x"}}},
+		{name: "help", handler: noop, flags: []flagSpec{{name: "help"}}},
+	}
+
+	for _, spec := range invalid {
+		registry := commands{mapper: make(map[string]commandSpec)}
+		if err := registry.register(spec); err == nil {
+			t.Errorf("register(%s) expected error, got nil", spec.name)
+		}
+	}
+}
+
+func TestParseCommandArgs_FlagsBetweenArgs(t *testing.T) {
+	cmd, err := parseCommandArgs(testCommandSpec(t, "search"), []string{"go", "--limit=5", "-java", "--following", "OR", "rust"})
+	if err != nil {
+		t.Fatalf("parseCommandArgs() returned unexpected error: %v", err)
+	}
+
+	if got := cmd.list("query"); !reflect.DeepEqual(got, []string{"go", "-java", "OR", "rust"}) {
+		t.Errorf("expected query words [go -java OR rust], got: %v", got)
+	}
+	if cmd.intValue("--limit") != 5 {
+		t.Errorf("expected limit 5, got: %d", cmd.intValue("--limit"))
+	}
+	if !cmd.boolValue("--following") || !cmd.isSet("--following") {
+		t.Errorf("expected --following to be set")
+	}
+	if cmd.isSet("--feed") || cmd.value("--feed") != "" {
+		t.Errorf("expected --feed to be unset, got: %s", cmd.value("--feed"))
+	}
+}
+
+func TestParseCommandArgs_Defaults(t *testing.T) {
+	cmd, err := parseCommandArgs(testCommandSpec(t, "agg"), []string{"1m"})
+	if err != nil {
+		t.Fatalf("parseCommandArgs() returned unexpected error: %v", err)
+	}
+
+	if cmd.durationValue("time_between_reqs").String() != "1m0s" {
+		t.Errorf("expected 1m0s, got: %v", cmd.durationValue("time_between_reqs"))
+	}
+	if cmd.intValue("concurrency") != defaultAggConcurrency || cmd.isSet("concurrency") {
+		t.Errorf("expected default concurrency %d, got: %d", defaultAggConcurrency, cmd.intValue("concurrency"))
+	}
+	if cmd.isSet("batch_size") {
+		t.Errorf("expected batch_size to be unset")
+	}
+}
+
+func TestParseCommandArgs_DoubleDash(t *testing.T) {
+	cmd, err := parseCommandArgs(testCommandSpec(t, "search"), []string{"--", "--limit", "go"})
+	if err != nil {
+		t.Fatalf("parseCommandArgs() returned unexpected error: %v", err)
+	}
+	if got := cmd.list("query"); !reflect.DeepEqual(got, []string{"--limit", "go"}) {
+		t.Errorf("expected query words [--limit go], got: %v", got)
+	}
+}
+
+func TestParseCommandArgs_Invalid(t *testing.T) {
+	invalid := map[string][]string{
+		"login":  {},
+		"follow": {"a", "b"},
+		"agg":    {"often"},
+		"search": {"--limit"},
+		"saved":  {"--tag"},
+		"browse": {"--include-read=maybe"},
+		"export": {"--file", "x.opml"},
+	}
+
+	for name, args := range invalid {
+		if _, err := parseCommandArgs(testCommandSpec(t, name), args); err == nil {
+			t.Errorf("%s %v expected error, got nil", name, args)
+		}
+	}
+}
+
+func TestParseCommandArgs_Help(t *testing.T) {
+	_, err := parseCommandArgs(testCommandSpec(t, "browse"), []string{"10", "--help"})
+	if !errors.Is(err, errHelpRequested) {
+		t.Errorf("expected errHelpRequested, got: %v", err)
+	}
+}
+
+func TestCommandSpec_Usage(t *testing.T) {
+	expected := map[string]string{
+		"login":  "gator login <username> [--api-key key]",
+		"export": "gator export [file]",
+		"save":   "gator save <post> [tags...]",
+		"saved":  "gator saved [--tag tag]",
+		"search": "gator search <query...> [--following] [--feed url] [--limit n]",
+	}
+
+	for name, want := range expected {
+		if got := testCommandSpec(t, name).usage(); got != want {
+			t.Errorf("usage of %s expected '%s', got: '%s'", name, want, got)
+		}
+	}
+
+	help := testCommandSpec(t, "browse").helpText()
+	if !strings.Contains(help, "--sort newest|oldest") || !strings.Contains(help, "(default newest)") {
+		t.Errorf("expected browse help to document --sort and its default, got:\n%s", help)
+	}
+}
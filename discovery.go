@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// tried on the site root when the page does not link to its feed
+var commonFeedPaths = []string{"/feed", "/feed.xml", "/rss", "/rss.xml", "/atom.xml", "/index.xml", "/feed.json"}
+
+var feedLinkTypes = []string{"application/rss+xml", "application/atom+xml", "application/feed+json"}
+
+var (
+	htmlLinkTag   = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	htmlBaseTag   = regexp.MustCompile(`(?is)<base\b[^>]*>`)
+	htmlAttribute = regexp.MustCompile(`(?s)([a-zA-Z_:][-a-zA-Z0-9_:.]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+/*
+A feed found by discoverFeeds, already fetched and parsed so the caller knows it works.
+*/
+type feedCandidate struct {
+	Url    string
+	Result *fetchResult
+}
+
+func (c feedCandidate) title() string {
+	if c.Result == nil || c.Result.Feed == nil {
+		return ""
+	}
+	return c.Result.Feed.Channel.Title
+}
+
+/*
+*
+This method returns the feeds behind a url given to addfeed. A feed url is returned as is (or where it moved to),
+for an html page it follows the <link rel="alternate"> tags of the page, then the common feed paths of the site,
+and keeps the candidates that fetch and parse as a feed.
+*/
+func (c *feedClient) discoverFeeds(ctx context.Context, pageUrl string) ([]feedCandidate, error) {
+	result, err := c.fetchFeed(ctx, pageUrl, feedCache{})
+	if err == nil {
+		return []feedCandidate{{Url: movedUrl(pageUrl, result), Result: result}}, nil
+	}
+
+	var fetchErr *fetchError
+	if !errors.As(err, &fetchErr) || !errors.Is(err, errNotAFeed) {
+		return nil, fmt.Errorf("cannot fetch %s: %v", pageUrl, err)
+	}
+
+	base, err := url.Parse(fetchErr.Url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %v", fetchErr.Url, err)
+	}
+	candidates := c.validFeeds(ctx, feedLinks(fetchErr.body, base))
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	var guesses []string
+	for _, path := range commonFeedPaths {
+		guesses = append(guesses, base.ResolveReference(&url.URL{Path: path}).String())
+	}
+	return c.validFeeds(ctx, guesses), nil
+}
+
+// fetches every url and keeps the ones that are feeds, urls redirecting to an already found feed are dropped
+func (c *feedClient) validFeeds(ctx context.Context, urls []string) []feedCandidate {
+	var candidates []feedCandidate
+	seen := make(map[string]bool)
+	for _, candidateUrl := range urls {
+		result, err := c.fetchFeed(ctx, candidateUrl, feedCache{})
+		if err != nil {
+			continue
+		}
+		candidateUrl = movedUrl(candidateUrl, result)
+		if seen[candidateUrl] {
+			continue
+		}
+		seen[candidateUrl] = true
+		candidates = append(candidates, feedCandidate{Url: candidateUrl, Result: result})
+	}
+	return candidates
+}
+
+func movedUrl(feedUrl string, result *fetchResult) string {
+	if result.PermanentUrl != "" {
+		return result.PermanentUrl
+	}
+	return feedUrl
+}
+
+/*
+*
+This method lists the feed urls an html page advertises with <link rel="alternate" type="application/rss+xml" href="...">
+(or atom / json feed), in document order and resolved against the page url or its <base href>.
+*/
+func feedLinks(page []byte, pageUrl *url.URL) []string {
+	base := pageUrl
+	if tag := htmlBaseTag.Find(page); tag != nil {
+		if href, ok := htmlAttributes(tag)["href"]; ok {
+			if parsed, err := pageUrl.Parse(href); err == nil {
+				base = parsed
+			}
+		}
+	}
+
+	var links []string
+	for _, tag := range htmlLinkTag.FindAll(page, -1) {
+		attributes := htmlAttributes(tag)
+		rel := strings.Fields(strings.ToLower(attributes["rel"]))
+		linkType := strings.ToLower(strings.TrimSpace(strings.Split(attributes["type"], ";")[0]))
+		if !slices.Contains(rel, "alternate") || !slices.Contains(feedLinkTypes, linkType) || attributes["href"] == "" {
+			continue
+		}
+
+		resolved, err := base.Parse(attributes["href"])
+		if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+			continue
+		}
+		if !slices.Contains(links, resolved.String()) {
+			links = append(links, resolved.String())
+		}
+	}
+	return links
+}
+
+// attribute names are lower cased, values unescaped
+func htmlAttributes(tag []byte) map[string]string {
+	attributes := make(map[string]string)
+	for _, match := range htmlAttribute.FindAllSubmatch(tag, -1) {
+		name := strings.ToLower(string(match[1]))
+		if _, ok := attributes[name]; ok {
+			continue
+		}
+		value := string(match[2]) + string(match[3]) + string(match[4])
+		attributes[name] = html.UnescapeString(strings.TrimSpace(value))
+	}
+	return attributes
+}
+
+/*
+*
+This method lists the candidates and reads the number of the one to use, an empty answer picks the first one.
+*/
+func chooseFeedCandidate(candidates []feedCandidate, in io.Reader, out io.Writer) (feedCandidate, error) {
+	fmt.Fprintln(out, "Several feeds were found:")
+	for idx, candidate := range candidates {
+		fmt.Fprintf(out, "  %d. %s (%s)\n", idx+1, candidate.title(), candidate.Url)
+	}
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(out, "Feed to add [1-%d, default 1]: ", len(candidates))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return feedCandidate{}, err
+			}
+			return feedCandidate{}, errors.New("no feed picked")
+		}
+
+		answer := strings.TrimSpace(scanner.Text())
+		if answer == "" {
+			return candidates[0], nil
+		}
+		choice, err := strconv.Atoi(answer)
+		if err == nil && choice >= 1 && choice <= len(candidates) {
+			return candidates[choice-1], nil
+		}
+		fmt.Fprintf(out, "%s is not a number between 1 and %d\n", answer, len(candidates))
+	}
+}
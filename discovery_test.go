@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testRSSDocument = `<rss version="2.0"><channel><title>%s</title><link>https://example.com</link></channel></rss>`
+
+func testFeedSite(t *testing.T, pages map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasPrefix(page, "<rss") {
+			w.Header().Set("Content-Type", "application/rss+xml")
+		} else {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		w.Write([]byte(page))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFeedLinks(t *testing.T) {
+	page := []byte(`<!DOCTYPE html><html><head>
+		<base href="/blog/">
+		<link rel="stylesheet" href="style.css">
+		<link rel="alternate" type="application/rss+xml" title="Posts" href="feed.xml">
+		<LINK REL="Alternate home" TYPE='application/atom+xml' HREF=/atom.xml>
+		<link rel=alternate type="text/html" hreflang=fr href="/fr/">
+		<link rel="alternate" type="application/rss+xml" href="feed.xml">
+		<link rel="alternate" type="application/feed+json" href="https://cdn.example.com/feed.json?a=1&amp;b=2">
+	</head><body></body></html>`)
+	pageUrl, _ := url.Parse("https://example.com/about")
+
+	expected := []string{
+		"https://example.com/blog/feed.xml",
+		"https://example.com/atom.xml",
+		"https://cdn.example.com/feed.json?a=1&b=2",
+	}
+	if got := feedLinks(page, pageUrl); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got: %v", expected, got)
+	}
+}
+
+func TestDiscoverFeeds_FeedUrl(t *testing.T) {
+	server := testFeedSite(t, map[string]string{"/rss.xml": fmt.Sprintf(testRSSDocument, "Blog")})
+
+	candidates, err := testFeedClient(t).discoverFeeds(context.Background(), server.URL+"/rss.xml")
+	if err != nil {
+		t.Fatalf("discoverFeeds() returned unexpected error: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Url != server.URL+"/rss.xml" || candidates[0].title() != "Blog" {
+		t.Errorf("expected the feed itself, got: %+v", candidates)
+	}
+}
+
+func TestDiscoverFeeds_HTMLLinks(t *testing.T) {
+	server := testFeedSite(t, map[string]string{
+		"/": `<html><head>
+			<link rel="alternate" type="application/rss+xml" href="/broken.xml">
+			<link rel="alternate" type="application/rss+xml" href="/posts.xml">
+			<link rel="alternate" type="application/atom+xml" href="/comments.xml">
+		</head></html>`,
+		"/posts.xml":    fmt.Sprintf(testRSSDocument, "Posts"),
+		"/comments.xml": fmt.Sprintf(testRSSDocument, "Comments"),
+		"/feed":         fmt.Sprintf(testRSSDocument, "Guessed"),
+	})
+
+	candidates, err := testFeedClient(t).discoverFeeds(context.Background(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("discoverFeeds() returned unexpected error: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].title() != "Posts" || candidates[1].Url != server.URL+"/comments.xml" {
+		t.Errorf("expected the two working linked feeds, got: %+v", candidates)
+	}
+}
+
+func TestDiscoverFeeds_CommonPaths(t *testing.T) {
+	server := testFeedSite(t, map[string]string{
+		"/blog/hello": `<html><head><title>No feed links</title></head></html>`,
+		"/index.xml":  fmt.Sprintf(testRSSDocument, "Hugo"),
+	})
+
+	candidates, err := testFeedClient(t).discoverFeeds(context.Background(), server.URL+"/blog/hello")
+	if err != nil {
+		t.Fatalf("discoverFeeds() returned unexpected error: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Url != server.URL+"/index.xml" {
+		t.Errorf("expected the feed at /index.xml, got: %+v", candidates)
+	}
+
+	if _, err := testFeedClient(t).discoverFeeds(context.Background(), server.URL+"/missing"); err == nil {
+		t.Errorf("expected an error for a page that does not exist")
+	}
+}
+
+func TestChooseFeedCandidate(t *testing.T) {
+	candidates := []feedCandidate{{Url: "https://example.com/posts.xml"}, {Url: "https://example.com/comments.xml"}}
+
+	var out bytes.Buffer
+	chosen, err := chooseFeedCandidate(candidates, strings.NewReader("7\nx\n2\n"), &out)
+	if err != nil {
+		t.Fatalf("chooseFeedCandidate() returned unexpected error: %v", err)
+	}
+	if chosen.Url != "https://example.com/comments.xml" {
+		t.Errorf("expected the second candidate, got: %s", chosen.Url)
+	}
+	if !strings.Contains(out.String(), "7 is not a number between 1 and 2") {
+		t.Errorf("expected invalid answers to be reported, got: %s", out.String())
+	}
+
+	chosen, err = chooseFeedCandidate(candidates, strings.NewReader("\n"), &out)
+	if err != nil || chosen.Url != "https://example.com/posts.xml" {
+		t.Errorf("expected an empty answer to pick the first candidate, got: %s %v", chosen.Url, err)
+	}
+	if _, err := chooseFeedCandidate(candidates, strings.NewReader(""), &out); err == nil {
+		t.Errorf("expected an error when nothing is picked")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bootDevGoRss/internal/config"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	defaultFetchTimeout = 30 * time.Second
+	// 10 MiB is plenty for any sane feed
+	defaultMaxBodyBytes = 10 << 20
+	defaultUserAgent    = "gator"
+)
+
+type feedClient struct {
+	httpClient   *http.Client
+	userAgent    string
+	maxBodyBytes int64
+}
+
+/*
+*
+This method builds the http client used for every feed fetch from the fetch section of the config file.
+*/
+func newFeedClient(cfg config.FetchConfig) (*feedClient, error) {
+	timeout := defaultFetchTimeout
+	if cfg.Timeout != "" {
+		parsed, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fetch timeout %q: %v", cfg.Timeout, err)
+		}
+		timeout = parsed
+	}
+
+	maxBodyBytes := int64(defaultMaxBodyBytes)
+	if cfg.MaxBodyBytes > 0 {
+		maxBodyBytes = cfg.MaxBodyBytes
+	}
+
+	userAgent := defaultUserAgent
+	if cfg.UserAgent != "" {
+		userAgent = cfg.UserAgent
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.ProxyUrl != "" {
+		proxyUrl, err := url.Parse(cfg.ProxyUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fetch proxy url %q: %v", cfg.ProxyUrl, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+
+	if cfg.TLSCAFile != "" || cfg.TLSInsecureSkipVerify {
+		tlsConfig := &tls.Config{InsecureSkipVerify: cfg.TLSInsecureSkipVerify}
+		if cfg.TLSCAFile != "" {
+			pem, err := os.ReadFile(cfg.TLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read fetch tls ca file: %v", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate found in fetch tls ca file %s", cfg.TLSCAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &feedClient{
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+		userAgent:    userAgent,
+		maxBodyBytes: maxBodyBytes,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errFeedNotFound    = errors.New("feed not found")
+	errFeedGone        = errors.New("feed is gone")
+	errFeedRateLimited = errors.New("rate limited by publisher")
+	errFeedServerError = errors.New("publisher server error")
+	errFeedBadStatus   = errors.New("unexpected http status")
+	errNotAFeed        = errors.New("response is not a feed")
+	errFeedTooLarge    = errors.New("feed is too large")
+)
+
+/*
+fetchError carries the details of a failed fetch, errors.Is matches it against one of the errFeed sentinels
+above so callers can act on the kind of failure without looking at status codes.
+*/
+type fetchError struct {
+	kind        error
+	Url         string
+	StatusCode  int
+	ContentType string
+	// only set for errFeedRateLimited when the publisher sent a Retry-After header
+	RetryAfter time.Duration
+	// only set for errNotAFeed, addfeed looks for feed links in the html page
+	body  []byte
+	cause error
+}
+
+func (e *fetchError) Error() string {
+	message := fmt.Sprintf("%v: %s", e.kind, e.Url)
+	if e.StatusCode != 0 {
+		message += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	if e.RetryAfter > 0 {
+		message += fmt.Sprintf(", retry after %s", e.RetryAfter)
+	}
+	if e.cause != nil {
+		message += fmt.Sprintf(": %v", e.cause)
+	}
+	return message
+}
+
+func (e *fetchError) Unwrap() []error {
+	if e.cause == nil {
+		return []error{e.kind}
+	}
+	return []error{e.kind, e.cause}
+}
+
+/*
+*
+This method maps a non 2xx/304 response onto a fetchError, it returns nil for the statuses fetchFeed can decode.
+*/
+func checkFeedResponse(resp *http.Response, now time.Time) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	fetchErr := &fetchError{
+		Url:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		fetchErr.kind = errFeedNotFound
+	case resp.StatusCode == http.StatusGone:
+		fetchErr.kind = errFeedGone
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable && resp.Header.Get("Retry-After") != "":
+		fetchErr.kind = errFeedRateLimited
+		fetchErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), now)
+	case resp.StatusCode >= 500:
+		fetchErr.kind = errFeedServerError
+	default:
+		fetchErr.kind = errFeedBadStatus
+	}
+	return fetchErr
+}
+
+/*
+*
+Retry-After is either a number of seconds or an HTTP date, a zero duration means we could not read it.
+*/
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -11,6 +11,38 @@ const configFileName = ".gatorconfig.json"
 type Config struct {
 	DbUrl       string `json:"db_url"`
 	CurrentUser string `json:"current_user_name"`
+	// API key of the current user, printed by register and apikey rotate
+	APIKey string `json:"api_key,omitempty"`
+	// in strict mode logged in commands are refused unless APIKey belongs to CurrentUser
+	Strict    bool            `json:"strict,omitempty"`
+	Fetch     FetchConfig     `json:"fetch,omitzero"`
+	Retention RetentionConfig `json:"retention,omitzero"`
+}
+
+/*
+Settings of the http client used to fetch feeds, zero values fall back to the defaults of the fetcher.
+*/
+type FetchConfig struct {
+	// Go duration string, e.g. "30s"
+	Timeout      string `json:"timeout,omitempty"`
+	MaxBodyBytes int64  `json:"max_body_bytes,omitempty"`
+	UserAgent    string `json:"user_agent,omitempty"`
+	// when empty the HTTP_PROXY / HTTPS_PROXY environment variables are used
+	ProxyUrl              string `json:"proxy_url,omitempty"`
+	TLSCAFile             string `json:"tls_ca_file,omitempty"`
+	TLSInsecureSkipVerify bool   `json:"tls_insecure_skip_verify,omitempty"`
+}
+
+/*
+How long posts are kept, used by the prune command and by agg. Saved posts are never removed, zero values keep everything.
+*/
+type RetentionConfig struct {
+	// Go duration string, posts published longer ago are removed, e.g. "720h"
+	MaxAge string `json:"max_age,omitempty"`
+	// only the newest posts of each feed are kept
+	MaxPostsPerFeed int64 `json:"max_posts_per_feed,omitempty"`
+	// Go duration string, agg prunes this often while it runs, it does not prune when empty
+	PruneInterval string `json:"prune_interval,omitempty"`
 }
 
 func Read() (Config, error) {
@@ -37,6 +69,16 @@ func (c *Config) SetUser(userName string) error {
 	return nil
 }
 
+/*
+*
+This method switches the current user along with its API key, an empty key removes the one of the previous user.
+*/
+func (c *Config) SetCredentials(userName, apiKey string) error {
+	c.CurrentUser = userName
+	c.APIKey = apiKey
+	return write(*c)
+}
+
 func getConfigFilePath() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -49,10 +91,10 @@ func getConfigFilePath() (string, error) {
 }
 
 /**
-* 0644
+* 0600, the file holds the API key
 * │││
-* ││└─ Others (everyone else):  4 = read only
-* │└── Group:                   4 = read only
+* ││└─ Others (everyone else):  0 = no access
+* │└── Group:                   0 = no access
 * └─── Owner:                   6 = read + write
  */
 func write(cfg Config) error {
@@ -66,9 +108,13 @@ func write(cfg Config) error {
 		return fmt.Errorf("cannot marshal config: %v", err)
 	}
 
-	if err := os.WriteFile(configFilePath, data, 0644); err != nil {
+	if err := os.WriteFile(configFilePath, data, 0600); err != nil {
 		return fmt.Errorf("cannot write config: %v", err)
 	}
+	// WriteFile keeps the mode of an existing file, tighten configs written by older versions
+	if err := os.Chmod(configFilePath, 0600); err != nil {
+		return fmt.Errorf("cannot restrict config permissions: %v", err)
+	}
 
 	return nil
 }
@@ -260,3 +260,116 @@ func TestWrite_OverwritesExistingConfig(t *testing.T) {
 		t.Errorf("expected CurrentUser 'new_user', got: '%s'", readConfig.CurrentUser)
 	}
 }
+
+func TestRead_FetchConfig(t *testing.T) {
+	configPath, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	configData := []byte(`{
+		"db_url": "postgres://localhost:5432/testdb",
+		"fetch": {
+			"timeout": "10s",
+			"max_body_bytes": 1048576,
+			"user_agent": "gator-test",
+			"proxy_url": "http://proxy.local:3128"
+		}
+	}`)
+	if err := os.WriteFile(configPath, configData, 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := Read()
+	if err != nil {
+		t.Fatalf("Read() returned unexpected error: %v", err)
+	}
+
+	expected := FetchConfig{
+		Timeout:      "10s",
+		MaxBodyBytes: 1048576,
+		UserAgent:    "gator-test",
+		ProxyUrl:     "http://proxy.local:3128",
+	}
+	if config.Fetch != expected {
+		t.Errorf("expected Fetch %+v, got: %+v", expected, config.Fetch)
+	}
+
+	// Fetch settings must survive SetUser rewriting the file
+	if err := config.SetUser("fetchuser"); err != nil {
+		t.Fatalf("SetUser() returned unexpected error: %v", err)
+	}
+	readConfig, err := Read()
+	if err != nil {
+		t.Fatalf("failed to read config after SetUser: %v", err)
+	}
+	if readConfig.Fetch != expected {
+		t.Errorf("expected Fetch to be preserved, got: %+v", readConfig.Fetch)
+	}
+}
+
+func TestSetCredentials_StoresKeyPrivately(t *testing.T) {
+	configPath, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	// an existing world readable config must be tightened
+	if err := os.WriteFile(configPath, []byte(`{"db_url":"postgres://localhost:5432/testdb","strict":true}`), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	if err := os.Chmod(configPath, 0644); err != nil {
+		t.Fatalf("failed to chmod test config: %v", err)
+	}
+
+	config, err := Read()
+	if err != nil {
+		t.Fatalf("Read() returned unexpected error: %v", err)
+	}
+	if err := config.SetCredentials("keyuser", "gator_secret"); err != nil {
+		t.Fatalf("SetCredentials() returned unexpected error: %v", err)
+	}
+
+	info, err := os.Stat(configPath)
+	if err != nil {
+		t.Fatalf("failed to stat config: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected config mode 0600, got: %v", info.Mode().Perm())
+	}
+
+	reread, err := Read()
+	if err != nil {
+		t.Fatalf("failed to read config after SetCredentials: %v", err)
+	}
+	if reread.CurrentUser != "keyuser" || reread.APIKey != "gator_secret" || !reread.Strict {
+		t.Errorf("expected keyuser with its key in strict mode, got: %+v", reread)
+	}
+
+	// switching user without a key must not keep the key of the previous one
+	if err := reread.SetCredentials("other", ""); err != nil {
+		t.Fatalf("SetCredentials() returned unexpected error: %v", err)
+	}
+	if reread.APIKey != "" {
+		t.Errorf("expected the api key to be cleared, got: %s", reread.APIKey)
+	}
+}
+
+func TestRead_RetentionConfig(t *testing.T) {
+	configPath, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	configData := []byte(`{
+		"db_url": "postgres://localhost:5432/testdb",
+		"retention": {"max_age": "720h", "max_posts_per_feed": 200, "prune_interval": "1h"}
+	}`)
+	if err := os.WriteFile(configPath, configData, 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	config, err := Read()
+	if err != nil {
+		t.Fatalf("Read() returned unexpected error: %v", err)
+	}
+
+	expected := RetentionConfig{MaxAge: "720h", MaxPostsPerFeed: 200, PruneInterval: "1h"}
+	if config.Retention != expected {
+		t.Errorf("expected Retention %+v, got: %+v", expected, config.Retention)
+	}
+}
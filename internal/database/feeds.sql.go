@@ -13,6 +13,74 @@ import (
 	"github.com/google/uuid"
 )
 
+const archiveFeed = `-- name: ArchiveFeed :exec
+update feeds set archived = true, disabled = true, consecutive_failures = 0, next_fetch_at = null where id = $1
+`
+
+func (q *Queries) ArchiveFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, archiveFeed, id)
+	return err
+}
+
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+update feeds set last_fetched_at = $1::timestamp
+where id in (
+    select id from feeds
+    where not disabled
+      and (last_fetched_at is null or last_fetched_at < $2::timestamp)
+      and (next_fetch_at is null or next_fetch_at <= $1::timestamp)
+    order by last_fetched_at asc nulls first
+    limit $3
+    for update skip locked
+)
+RETURNING id, name, url, last_fetched_at, user_id, etag, last_modified, consecutive_failures, last_error, next_fetch_at, disabled, link, description, image_url, archived
+`
+
+type ClaimFeedsToFetchParams struct {
+	FetchedAt   time.Time
+	StaleBefore time.Time
+	BatchSize   int32
+}
+
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, arg ClaimFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch, arg.FetchedAt, arg.StaleBefore, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.LastFetchedAt,
+			&i.UserID,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.Disabled,
+			&i.Link,
+			&i.Description,
+			&i.ImageUrl,
+			&i.Archived,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds (id, name, url, last_fetched_at, user_id)
 VALUES (
@@ -22,7 +90,7 @@ VALUES (
         $4,
    $5
 )
-RETURNING id, name, url, last_fetched_at, user_id
+RETURNING id, name, url, last_fetched_at, user_id, etag, last_modified, consecutive_failures, last_error, next_fetch_at, disabled, link, description, image_url, archived
 `
 
 type CreateFeedParams struct {
@@ -48,24 +116,35 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.NextFetchAt,
+		&i.Disabled,
+		&i.Link,
+		&i.Description,
+		&i.ImageUrl,
+		&i.Archived,
 	)
 	return i, err
 }
 
 const createFeedFollow = `-- name: CreateFeedFollow :one
 with inserted_feed_follow as (
-    INSERT INTO feed_follows (id, created_at, updated_at, feed_id, user_id)
+    INSERT INTO feed_follows (id, created_at, updated_at, feed_id, user_id, category)
     VALUES (
         $1,
         $2,
         $3,
         $4,
-        $5
+        $5,
+        $6
     )
-    RETURNING id, created_at, updated_at, feed_id, user_id
+    RETURNING id, created_at, updated_at, feed_id, user_id, category
 )
 select
-    inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.feed_id, inserted_feed_follow.user_id,
+    inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.feed_id, inserted_feed_follow.user_id, inserted_feed_follow.category,
     feeds.name as feed_name,
     users.name as user_name
 from inserted_feed_follow
@@ -79,6 +158,7 @@ type CreateFeedFollowParams struct {
 	UpdatedAt time.Time
 	FeedID    uuid.UUID
 	UserID    uuid.UUID
+	Category  string
 }
 
 type CreateFeedFollowRow struct {
@@ -87,6 +167,7 @@ type CreateFeedFollowRow struct {
 	UpdatedAt time.Time
 	FeedID    uuid.UUID
 	UserID    uuid.UUID
+	Category  string
 	FeedName  string
 	UserName  string
 }
@@ -98,6 +179,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		arg.UpdatedAt,
 		arg.FeedID,
 		arg.UserID,
+		arg.Category,
 	)
 	var i CreateFeedFollowRow
 	err := row.Scan(
@@ -106,12 +188,31 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.FeedID,
 		&i.UserID,
+		&i.Category,
 		&i.FeedName,
 		&i.UserName,
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+delete from feeds where id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const deleteFeedFollows = `-- name: DeleteFeedFollows :exec
+delete from feed_follows where feed_id = $1
+`
+
+func (q *Queries) DeleteFeedFollows(ctx context.Context, feedID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeedFollows, feedID)
+	return err
+}
+
 const deleteFollow = `-- name: DeleteFollow :exec
 delete from feed_follows where user_id = $1 and feed_id = $2
 `
@@ -126,8 +227,44 @@ func (q *Queries) DeleteFollow(ctx context.Context, arg DeleteFollowParams) erro
 	return err
 }
 
+const enableFeed = `-- name: EnableFeed :exec
+update feeds set consecutive_failures = 0, last_error = '', next_fetch_at = null, disabled = false where id = $1
+`
+
+func (q *Queries) EnableFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, enableFeed, id)
+	return err
+}
+
+const getFeedById = `-- name: GetFeedById :one
+select id, name, url, last_fetched_at, user_id, etag, last_modified, consecutive_failures, last_error, next_fetch_at, disabled, link, description, image_url, archived from feeds where id = $1
+`
+
+func (q *Queries) GetFeedById(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedById, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.NextFetchAt,
+		&i.Disabled,
+		&i.Link,
+		&i.Description,
+		&i.ImageUrl,
+		&i.Archived,
+	)
+	return i, err
+}
+
 const getFeedByUrl = `-- name: GetFeedByUrl :one
-select id, name, url, last_fetched_at, user_id from feeds where url = $1
+select id, name, url, last_fetched_at, user_id, etag, last_modified, consecutive_failures, last_error, next_fetch_at, disabled, link, description, image_url, archived from feeds where url = $1
 `
 
 func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
@@ -139,6 +276,16 @@ func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.NextFetchAt,
+		&i.Disabled,
+		&i.Link,
+		&i.Description,
+		&i.ImageUrl,
+		&i.Archived,
 	)
 	return i, err
 }
@@ -147,6 +294,7 @@ const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
 select
     feed_follows.feed_id,
     feed_follows.user_id,
+    feed_follows.category,
     feeds.name as feed_name,
     feeds.url as feed_url
 from feed_follows
@@ -156,6 +304,7 @@ from feed_follows
 type GetFeedFollowsForUserRow struct {
 	FeedID   uuid.UUID
 	UserID   uuid.UUID
+	Category string
 	FeedName string
 	FeedUrl  string
 }
@@ -172,6 +321,7 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 		if err := rows.Scan(
 			&i.FeedID,
 			&i.UserID,
+			&i.Category,
 			&i.FeedName,
 			&i.FeedUrl,
 		); err != nil {
@@ -189,7 +339,7 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 }
 
 const getFeeds = `-- name: GetFeeds :many
-select id, name, url, last_fetched_at, user_id from feeds
+select id, name, url, last_fetched_at, user_id, etag, last_modified, consecutive_failures, last_error, next_fetch_at, disabled, link, description, image_url, archived from feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -207,6 +357,16 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.LastFetchedAt,
 			&i.UserID,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.Disabled,
+			&i.Link,
+			&i.Description,
+			&i.ImageUrl,
+			&i.Archived,
 		); err != nil {
 			return nil, err
 		}
@@ -221,33 +381,114 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 	return items, nil
 }
 
-const getNextFeedToFetched = `-- name: GetNextFeedToFetched :one
-select id, name, url, last_fetched_at, user_id from feeds order by last_fetched_at asc nulls first limit 1
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+update feed_follows set feed_id = $1
+where feed_id = $2
+  and user_id not in (select user_id from feed_follows where feed_id = $1)
 `
 
-func (q *Queries) GetNextFeedToFetched(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetched)
-	var i Feed
-	err := row.Scan(
-		&i.ID,
-		&i.Name,
-		&i.Url,
-		&i.LastFetchedAt,
-		&i.UserID,
+type MoveFeedFollowsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const recordFeedFailure = `-- name: RecordFeedFailure :exec
+update feeds set consecutive_failures = $1, last_error = $2, next_fetch_at = $3, disabled = $4 where id = $5
+`
+
+type RecordFeedFailureParams struct {
+	ConsecutiveFailures int32
+	LastError           string
+	NextFetchAt         sql.NullTime
+	Disabled            bool
+	ID                  uuid.UUID
+}
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedFailure,
+		arg.ConsecutiveFailures,
+		arg.LastError,
+		arg.NextFetchAt,
+		arg.Disabled,
+		arg.ID,
 	)
-	return i, err
+	return err
 }
 
-const markFeedFetched = `-- name: MarkFeedFetched :exec
-update feeds set last_fetched_at = $1 where feeds.url = $2
+const recordFeedSuccess = `-- name: RecordFeedSuccess :exec
+update feeds set consecutive_failures = 0, last_error = '', next_fetch_at = null where id = $1
 `
 
-type MarkFeedFetchedParams struct {
-	LastFetchedAt sql.NullTime
-	Url           string
+func (q *Queries) RecordFeedSuccess(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, recordFeedSuccess, id)
+	return err
+}
+
+const renameFeed = `-- name: RenameFeed :exec
+update feeds set name = $1 where id = $2
+`
+
+type RenameFeedParams struct {
+	Name string
+	ID   uuid.UUID
+}
+
+func (q *Queries) RenameFeed(ctx context.Context, arg RenameFeedParams) error {
+	_, err := q.db.ExecContext(ctx, renameFeed, arg.Name, arg.ID)
+	return err
+}
+
+const updateFeedCache = `-- name: UpdateFeedCache :exec
+update feeds set etag = $1, last_modified = $2 where id = $3
+`
+
+type UpdateFeedCacheParams struct {
+	Etag         string
+	LastModified string
+	ID           uuid.UUID
+}
+
+func (q *Queries) UpdateFeedCache(ctx context.Context, arg UpdateFeedCacheParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedCache, arg.Etag, arg.LastModified, arg.ID)
+	return err
+}
+
+const updateFeedMetadata = `-- name: UpdateFeedMetadata :exec
+update feeds set link = $1, description = $2, image_url = $3 where id = $4
+`
+
+type UpdateFeedMetadataParams struct {
+	Link        string
+	Description string
+	ImageUrl    string
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateFeedMetadata(ctx context.Context, arg UpdateFeedMetadataParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedMetadata,
+		arg.Link,
+		arg.Description,
+		arg.ImageUrl,
+		arg.ID,
+	)
+	return err
+}
+
+const updateFeedUrl = `-- name: UpdateFeedUrl :exec
+update feeds set url = $1 where id = $2
+`
+
+type UpdateFeedUrlParams struct {
+	Url string
+	ID  uuid.UUID
 }
 
-func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.LastFetchedAt, arg.Url)
+func (q *Queries) UpdateFeedUrl(ctx context.Context, arg UpdateFeedUrlParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedUrl, arg.Url, arg.ID)
 	return err
 }
@@ -12,11 +12,21 @@ import (
 )
 
 type Feed struct {
-	ID            uuid.UUID
-	Name          string
-	Url           string
-	LastFetchedAt sql.NullTime
-	UserID        uuid.UUID
+	ID                  uuid.UUID
+	Name                string
+	Url                 string
+	LastFetchedAt       sql.NullTime
+	UserID              uuid.UUID
+	Etag                string
+	LastModified        string
+	ConsecutiveFailures int32
+	LastError           string
+	NextFetchAt         sql.NullTime
+	Disabled            bool
+	Link                string
+	Description         string
+	ImageUrl            string
+	Archived            bool
 }
 
 type FeedFollow struct {
@@ -25,22 +35,44 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	FeedID    uuid.UUID
 	UserID    uuid.UUID
+	Category  string
 }
 
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description string
-	PublishedAt time.Time
-	FeedID      uuid.UUID
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Title        string
+	Url          string
+	Description  string
+	PublishedAt  time.Time
+	FeedID       uuid.UUID
+	SearchVector interface{}
 }
 
-type User struct {
-	ID        uuid.UUID
+type PostRead struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+type PrunedPost struct {
+	Url      string
+	PrunedAt time.Time
+}
+
+type SavedPost struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	Tags      []string
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
+}
+
+type User struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Name       string
+	IsAdmin    bool
+	ApiKeyHash sql.NullString
 }
@@ -7,24 +7,213 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-const createPost = `-- name: CreatePost :exec
+const browsePostsNewestFirst = `-- name: BrowsePostsNewestFirst :many
+select posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name as feed_name, (post_reads.post_id is not null)::boolean as is_read
+from posts
+    inner join feeds on feeds.id = posts.feed_id
+    left join post_reads on post_reads.post_id = posts.id and post_reads.user_id = $1
+where ($2::boolean or exists (
+        select 1 from feed_follows where feed_follows.feed_id = posts.feed_id and feed_follows.user_id = $1
+    ))
+  and ($3::boolean or post_reads.post_id is null)
+  and ($4::uuid is null or posts.feed_id = $4)
+  and ($5::timestamp is null or posts.published_at >= $5)
+  and ($6::timestamp is null or posts.published_at < $6)
+  and ($7::timestamp is null
+    or (posts.published_at, posts.id) < ($7, $8::uuid))
+order by posts.published_at desc, posts.id desc
+limit $9 offset $10
+`
+
+type BrowsePostsNewestFirstParams struct {
+	UserID            uuid.UUID
+	AllFeeds          bool
+	IncludeRead       bool
+	FeedID            uuid.NullUUID
+	Since             sql.NullTime
+	Until             sql.NullTime
+	CursorPublishedAt sql.NullTime
+	CursorID          uuid.NullUUID
+	PostLimit         int32
+	PostOffset        int32
+}
+
+type BrowsePostsNewestFirstRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	FeedName    string
+	IsRead      bool
+}
+
+func (q *Queries) BrowsePostsNewestFirst(ctx context.Context, arg BrowsePostsNewestFirstParams) ([]BrowsePostsNewestFirstRow, error) {
+	rows, err := q.db.QueryContext(ctx, browsePostsNewestFirst,
+		arg.UserID,
+		arg.AllFeeds,
+		arg.IncludeRead,
+		arg.FeedID,
+		arg.Since,
+		arg.Until,
+		arg.CursorPublishedAt,
+		arg.CursorID,
+		arg.PostLimit,
+		arg.PostOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BrowsePostsNewestFirstRow
+	for rows.Next() {
+		var i BrowsePostsNewestFirstRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.FeedName,
+			&i.IsRead,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const browsePostsOldestFirst = `-- name: BrowsePostsOldestFirst :many
+select posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name as feed_name, (post_reads.post_id is not null)::boolean as is_read
+from posts
+    inner join feeds on feeds.id = posts.feed_id
+    left join post_reads on post_reads.post_id = posts.id and post_reads.user_id = $1
+where ($2::boolean or exists (
+        select 1 from feed_follows where feed_follows.feed_id = posts.feed_id and feed_follows.user_id = $1
+    ))
+  and ($3::boolean or post_reads.post_id is null)
+  and ($4::uuid is null or posts.feed_id = $4)
+  and ($5::timestamp is null or posts.published_at >= $5)
+  and ($6::timestamp is null or posts.published_at < $6)
+  and ($7::timestamp is null
+    or (posts.published_at, posts.id) > ($7, $8::uuid))
+order by posts.published_at asc, posts.id asc
+limit $9 offset $10
+`
+
+type BrowsePostsOldestFirstParams struct {
+	UserID            uuid.UUID
+	AllFeeds          bool
+	IncludeRead       bool
+	FeedID            uuid.NullUUID
+	Since             sql.NullTime
+	Until             sql.NullTime
+	CursorPublishedAt sql.NullTime
+	CursorID          uuid.NullUUID
+	PostLimit         int32
+	PostOffset        int32
+}
+
+type BrowsePostsOldestFirstRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	FeedName    string
+	IsRead      bool
+}
+
+func (q *Queries) BrowsePostsOldestFirst(ctx context.Context, arg BrowsePostsOldestFirstParams) ([]BrowsePostsOldestFirstRow, error) {
+	rows, err := q.db.QueryContext(ctx, browsePostsOldestFirst,
+		arg.UserID,
+		arg.AllFeeds,
+		arg.IncludeRead,
+		arg.FeedID,
+		arg.Since,
+		arg.Until,
+		arg.CursorPublishedAt,
+		arg.CursorID,
+		arg.PostLimit,
+		arg.PostOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BrowsePostsOldestFirstRow
+	for rows.Next() {
+		var i BrowsePostsOldestFirstRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.FeedName,
+			&i.IsRead,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countPostsOfFeed = `-- name: CountPostsOfFeed :one
+select count(*) from posts where feed_id = $1
+`
+
+func (q *Queries) CountPostsOfFeed(ctx context.Context, feedID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPostsOfFeed, feedID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createPost = `-- name: CreatePost :execrows
 INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1,
-        $2,
-        $3,
-        $4,
-        $5,
-        $6,
-        $7,
-        $8
-)
+SELECT $1::uuid,
+       $2::timestamp,
+       $3::timestamp,
+       $4::text,
+       $5::text,
+       $6::text,
+       $7::timestamp,
+       $8::uuid
+WHERE NOT EXISTS (select 1 from pruned_posts where pruned_posts.url = $5)
 ON CONFLICT (url) DO NOTHING
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
 `
 
 type CreatePostParams struct {
@@ -38,8 +227,8 @@ type CreatePostParams struct {
 	FeedID      uuid.UUID
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) error {
-	_, err := q.db.ExecContext(ctx, createPost,
+func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createPost,
 		arg.ID,
 		arg.CreatedAt,
 		arg.UpdatedAt,
@@ -49,22 +238,312 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) error {
 		arg.PublishedAt,
 		arg.FeedID,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deletePostsOfFeed = `-- name: DeletePostsOfFeed :execrows
+delete from posts
+where feed_id = $1
+  and not exists (select 1 from saved_posts where saved_posts.post_id = posts.id)
+`
+
+func (q *Queries) DeletePostsOfFeed(ctx context.Context, feedID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deletePostsOfFeed, feedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFollowedPosts = `-- name: GetFollowedPosts :many
+select posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name as feed_name, feeds.url as feed_url, feed_follows.category
+from posts
+    inner join feed_follows on feed_follows.feed_id = posts.feed_id and feed_follows.user_id = $1
+    inner join feeds on feeds.id = posts.feed_id
+where ($2::text is null
+    or feed_follows.category = $2
+    or starts_with(feed_follows.category, $2 || '/'))
+order by posts.published_at desc, posts.id desc
+limit $3
+`
+
+type GetFollowedPostsParams struct {
+	UserID    uuid.UUID
+	Category  sql.NullString
+	PostLimit int32
+}
+
+type GetFollowedPostsRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	FeedName    string
+	FeedUrl     string
+	Category    string
+}
+
+func (q *Queries) GetFollowedPosts(ctx context.Context, arg GetFollowedPostsParams) ([]GetFollowedPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedPosts, arg.UserID, arg.Category, arg.PostLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFollowedPostsRow
+	for rows.Next() {
+		var i GetFollowedPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.Category,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostById = `-- name: GetPostById :one
+select id, created_at, updated_at, title, url, description, published_at, feed_id from posts where id = $1
+`
+
+type GetPostByIdRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+}
+
+func (q *Queries) GetPostById(ctx context.Context, id uuid.UUID) (GetPostByIdRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostById, id)
+	var i GetPostByIdRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+	)
+	return i, err
+}
+
+const getPostByUrl = `-- name: GetPostByUrl :one
+select id, created_at, updated_at, title, url, description, published_at, feed_id from posts where url = $1
+`
+
+type GetPostByUrlRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+}
+
+func (q *Queries) GetPostByUrl(ctx context.Context, url string) (GetPostByUrlRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostByUrl, url)
+	var i GetPostByUrlRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+	)
+	return i, err
 }
 
 const getPosts = `-- name: GetPosts :many
 select id, created_at, updated_at, title, url, description, published_at, feed_id from posts order by created_at desc limit $1
 `
 
-func (q *Queries) GetPosts(ctx context.Context, limit int32) ([]Post, error) {
+type GetPostsRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+}
+
+func (q *Queries) GetPosts(ctx context.Context, limit int32) ([]GetPostsRow, error) {
 	rows, err := q.db.QueryContext(ctx, getPosts, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostsRow
+	for rows.Next() {
+		var i GetPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const movePostsToFeed = `-- name: MovePostsToFeed :exec
+update posts set feed_id = $1 where feed_id = $2
+`
+
+type MovePostsToFeedParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MovePostsToFeed(ctx context.Context, arg MovePostsToFeedParams) error {
+	_, err := q.db.ExecContext(ctx, movePostsToFeed, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const pruneExcessPosts = `-- name: PruneExcessPosts :execrows
+with pruned as (
+    delete from posts
+    where id in (
+        select ranked.id from (
+            select posts.id, row_number() over (partition by posts.feed_id order by posts.published_at desc, posts.id desc) as position
+            from posts
+            where not exists (select 1 from saved_posts where saved_posts.post_id = posts.id)
+        ) ranked
+        where ranked.position > $1::bigint
+    )
+    returning url
+)
+insert into pruned_posts (url, pruned_at)
+select url, now() from pruned
+on conflict (url) do update set pruned_at = excluded.pruned_at
+`
+
+func (q *Queries) PruneExcessPosts(ctx context.Context, maxPostsPerFeed int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneExcessPosts, maxPostsPerFeed)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const pruneExpiredPosts = `-- name: PruneExpiredPosts :execrows
+with pruned as (
+    delete from posts
+    where published_at < $1
+      and not exists (select 1 from saved_posts where saved_posts.post_id = posts.id)
+    returning url
+)
+insert into pruned_posts (url, pruned_at)
+select url, now() from pruned
+on conflict (url) do update set pruned_at = excluded.pruned_at
+`
+
+func (q *Queries) PruneExpiredPosts(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneExpiredPosts, publishedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const searchPosts = `-- name: SearchPosts :many
+select posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name as feed_name, ts_rank(posts.search_vector, query)::real as rank
+from posts
+    inner join feeds on feeds.id = posts.feed_id,
+    to_tsquery('english', $1) query
+where posts.search_vector @@ query
+  and ($2::uuid is null or posts.feed_id = $2)
+  and (not $3::boolean or exists (
+        select 1 from feed_follows where feed_follows.feed_id = posts.feed_id and feed_follows.user_id = $4
+    ))
+order by rank desc, posts.published_at desc
+limit $5
+`
+
+type SearchPostsParams struct {
+	Query        string
+	FeedID       uuid.NullUUID
+	OnlyFollowed bool
+	UserID       uuid.UUID
+	PostLimit    int32
+}
+
+type SearchPostsRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	FeedName    string
+	Rank        float32
+}
+
+func (q *Queries) SearchPosts(ctx context.Context, arg SearchPostsParams) ([]SearchPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPosts,
+		arg.Query,
+		arg.FeedID,
+		arg.OnlyFollowed,
+		arg.UserID,
+		arg.PostLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsRow
 	for rows.Next() {
-		var i Post
+		var i SearchPostsRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
@@ -74,6 +553,8 @@ func (q *Queries) GetPosts(ctx context.Context, limit int32) ([]Post, error) {
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.FeedName,
+			&i.Rank,
 		); err != nil {
 			return nil, err
 		}
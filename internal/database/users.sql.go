@@ -7,27 +7,43 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const countUsers = `-- name: CountUsers :one
+select count(*) from users
+`
+
+func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, is_admin, api_key_hash)
 VALUES (
    $1,
    $2,
    $3,
-   $4
+   $4,
+   $5,
+   $6
 )
-RETURNING id, created_at, updated_at, name
+RETURNING id, created_at, updated_at, name, is_admin, api_key_hash
 `
 
 type CreateUserParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Name       string
+	IsAdmin    bool
+	ApiKeyHash sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -36,6 +52,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.Name,
+		arg.IsAdmin,
+		arg.ApiKeyHash,
 	)
 	var i User
 	err := row.Scan(
@@ -43,10 +61,21 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.IsAdmin,
+		&i.ApiKeyHash,
 	)
 	return i, err
 }
 
+const deleteUser = `-- name: DeleteUser :exec
+delete from users where id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
 const deleteUsers = `-- name: DeleteUsers :exec
 delete from users
 `
@@ -57,7 +86,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const getUser = `-- name: GetUser :one
-select id, created_at, updated_at, name from users where name = $1
+select id, created_at, updated_at, name, is_admin, api_key_hash from users where name = $1
 `
 
 func (q *Queries) GetUser(ctx context.Context, name string) (User, error) {
@@ -68,12 +97,32 @@ func (q *Queries) GetUser(ctx context.Context, name string) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.IsAdmin,
+		&i.ApiKeyHash,
+	)
+	return i, err
+}
+
+const getUserByApiKeyHash = `-- name: GetUserByApiKeyHash :one
+select id, created_at, updated_at, name, is_admin, api_key_hash from users where api_key_hash = $1
+`
+
+func (q *Queries) GetUserByApiKeyHash(ctx context.Context, apiKeyHash sql.NullString) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByApiKeyHash, apiKeyHash)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.IsAdmin,
+		&i.ApiKeyHash,
 	)
 	return i, err
 }
 
 const getUserById = `-- name: GetUserById :one
-select id, created_at, updated_at, name from users where id = $1
+select id, created_at, updated_at, name, is_admin, api_key_hash from users where id = $1
 `
 
 func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
@@ -84,12 +133,14 @@ func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.IsAdmin,
+		&i.ApiKeyHash,
 	)
 	return i, err
 }
 
 const getUsers = `-- name: GetUsers :many
-select id, created_at, updated_at, name from users
+select id, created_at, updated_at, name, is_admin, api_key_hash from users
 `
 
 func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
@@ -106,6 +157,8 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Name,
+			&i.IsAdmin,
+			&i.ApiKeyHash,
 		); err != nil {
 			return nil, err
 		}
@@ -119,3 +172,33 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	}
 	return items, nil
 }
+
+const setUserAdmin = `-- name: SetUserAdmin :exec
+update users set is_admin = $1, updated_at = $2 where id = $3
+`
+
+type SetUserAdminParams struct {
+	IsAdmin   bool
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) SetUserAdmin(ctx context.Context, arg SetUserAdminParams) error {
+	_, err := q.db.ExecContext(ctx, setUserAdmin, arg.IsAdmin, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const setUserApiKeyHash = `-- name: SetUserApiKeyHash :exec
+update users set api_key_hash = $1, updated_at = $2 where id = $3
+`
+
+type SetUserApiKeyHashParams struct {
+	ApiKeyHash sql.NullString
+	UpdatedAt  time.Time
+	ID         uuid.UUID
+}
+
+func (q *Queries) SetUserApiKeyHash(ctx context.Context, arg SetUserApiKeyHashParams) error {
+	_, err := q.db.ExecContext(ctx, setUserApiKeyHash, arg.ApiKeyHash, arg.UpdatedAt, arg.ID)
+	return err
+}
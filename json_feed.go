@@ -0,0 +1,100 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const jsonFeedVersionPrefix = "https://jsonfeed.org/version/"
+
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageUrl string         `json:"home_page_url"`
+	FeedUrl     string         `json:"feed_url"`
+	Description string         `json:"description"`
+	Icon        string         `json:"icon"`
+	Favicon     string         `json:"favicon"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+type JSONFeedItem struct {
+	ID            string `json:"id"`
+	Url           string `json:"url"`
+	ExternalUrl   string `json:"external_url"`
+	Title         string `json:"title"`
+	ContentHtml   string `json:"content_html"`
+	ContentText   string `json:"content_text"`
+	Summary       string `json:"summary"`
+	DatePublished string `json:"date_published"`
+	DateModified  string `json:"date_modified"`
+}
+
+/*
+*
+JSON Feed is served as application/feed+json, but plenty of servers still answer with application/json.
+*/
+func isJSONContentType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return mediaType == "application/feed+json" || mediaType == "application/json"
+}
+
+func parseJSONFeed(data []byte) (*RSSFeed, error) {
+	var jsonFeed JSONFeed
+	if err := json.Unmarshal(data, &jsonFeed); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(jsonFeed.Version, jsonFeedVersionPrefix) {
+		return nil, fmt.Errorf("unsupported json feed version %q", jsonFeed.Version)
+	}
+
+	return jsonFeed.toRSSFeed(), nil
+}
+
+/*
+*
+This method maps a JSON Feed document onto the RSS model so the rest of the app only deals with one shape.
+*/
+func (j *JSONFeed) toRSSFeed() *RSSFeed {
+	var feed RSSFeed
+	feed.Channel.Title = j.Title
+	feed.Channel.Link = j.HomePageUrl
+	feed.Channel.Description = j.Description
+	if image := cmp.Or(j.Icon, j.Favicon); image != "" {
+		feed.Channel.Image = &RSSImage{Url: image, Title: j.Title, Link: j.HomePageUrl}
+	}
+
+	for _, item := range j.Items {
+		link := item.Url
+		if link == "" {
+			link = item.ExternalUrl
+		}
+		description := item.Summary
+		if description == "" {
+			description = item.ContentHtml
+		}
+		if description == "" {
+			description = item.ContentText
+		}
+		pubDate := item.DatePublished
+		if pubDate == "" {
+			pubDate = item.DateModified
+		}
+		// JSON Feed dates are RFC3339, keep them in the same layout as the RSS pubDate.
+		if parsed, err := time.Parse(time.RFC3339, pubDate); err == nil {
+			pubDate = parsed.Format(time.RFC1123Z)
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       item.Title,
+			Link:        link,
+			Description: description,
+			PubDate:     pubDate,
+		})
+	}
+
+	return &feed
+}
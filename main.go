@@ -12,6 +12,7 @@ import (
 	"bootDevGoRss/internal/config"
 	"log"
 	"os"
+	"strconv"
 )
 
 // TIP <p>To run your code, right-click the code and select <b>Run</b>.</p> <p>Alternatively, click
@@ -29,51 +30,36 @@ func main() {
 	}
 	dbQueries := database.New(db)
 
+	fetchClient, err := newFeedClient(configData.Fetch)
+	if err != nil {
+		log.Fatalf("Error in fetch config %v", err)
+	}
+
+	retention, err := newRetentionPolicy(configData.Retention)
+	if err != nil {
+		log.Fatalf("Error in retention config %v", err)
+	}
+
 	stateData := state{
+		db:            db,
 		configData:    &configData,
 		dbQueriesData: dbQueries,
+		feedClient:    fetchClient,
+		retention:     retention,
 	}
 
 	commandsData := commands{
-		mapper: make(map[string]func(*state, command) error),
-	}
-	if err := commandsData.register("login", handlerLogin); err != nil {
-		log.Fatalf("error in login command: %v", err)
-	}
-	if err := commandsData.register("register", handlerRegister); err != nil {
-		log.Fatalf("error in register command: %v", err)
-	}
-	if err := commandsData.register("reset", handlerDelete); err != nil {
-		log.Fatalf("error in reset command: %v", err)
-	}
-	if err := commandsData.register("users", handlerGetUsers); err != nil {
-		log.Fatalf("error in users command: %v", err)
+		mapper: make(map[string]commandSpec),
 	}
-	if err := commandsData.register("agg", handlerAggCommand); err != nil {
-		log.Fatalf("error in agg command: %v", err)
-	}
-	if err := commandsData.register("addfeed", middlewareLoggedIn(handlerAddFeed)); err != nil {
-		log.Fatalf("error in addfeed command: %v", err)
-	}
-	if err := commandsData.register("feeds", handlerFeeds); err != nil {
-		log.Fatalf("error in feeds command: %v", err)
-	}
-	if err := commandsData.register("follow", middlewareLoggedIn(handlerFollow)); err != nil {
-		log.Fatalf("error in follow command: %v", err)
-	}
-	if err := commandsData.register("following", middlewareLoggedIn(handlerFollowing)); err != nil {
-		log.Fatalf("error in following command: %v", err)
-	}
-	if err := commandsData.register("unfollow", middlewareLoggedIn(handlerUnFollow)); err != nil {
-		log.Fatalf("error in unfollow command: %v", err)
-	}
-	if err := commandsData.register("browse", handlerBrowse); err != nil {
-		log.Fatalf("error in browse command: %v", err)
+	for _, spec := range append(commandSpecs(), commandsData.helpSpec()) {
+		if err := commandsData.register(spec); err != nil {
+			log.Fatalf("error in %s command: %v", spec.name, err)
+		}
 	}
 
 	// Why two? The first argument is automatically the program name, which we ignore, and we require a command name.
 	if len(os.Args) < 2 {
-		log.Fatal("Usage: cli <command> [args...]")
+		log.Fatal("Usage: cli <command> [args...], run help to list the commands")
 	}
 
 	cmdName := os.Args[1]
@@ -87,3 +73,238 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+/*
+*
+Every command of the cli, in the order help lists them. Logged in commands are wrapped by middlewareLoggedIn.
+*/
+func commandSpecs() []commandSpec {
+	feedUrlArg := argSpec{name: "url", description: "url of the feed"}
+	postArg := argSpec{name: "post", description: "id printed by browse, or url of the post"}
+
+	return []commandSpec{
+		{
+			name:        "register",
+			description: "Create a user and log in as it, the first user is an admin",
+			args:        []argSpec{{name: "username", description: "name of the new user"}},
+			handler:     handlerRegister,
+		},
+		{
+			name:        "login",
+			description: "Log in as an existing user",
+			args:        []argSpec{{name: "username", description: "name of the user"}},
+			flags: []flagSpec{
+				{name: "api-key", placeholder: "key", description: "API key of the user, required in strict mode"},
+			},
+			handler: handlerLogin,
+		},
+		{
+			name:        "apikey",
+			description: "Replace the API key of the logged in user",
+			args: []argSpec{
+				{name: "action", choices: []string{"rotate"}, description: "rotate: generate a new key, the old one stops working"},
+			},
+			handler: middlewareLoggedIn(handlerAPIKey),
+		},
+		{
+			name:        "users",
+			description: "List the users",
+			handler:     handlerGetUsers,
+		},
+		{
+			name:        "promote",
+			description: "Grant admin rights to a user (admins only)",
+			args:        []argSpec{{name: "username", description: "name of the user to promote"}},
+			handler:     middlewareLoggedIn(handlerPromote),
+		},
+		{
+			name:        "reset",
+			description: "Delete every user (admins only in strict mode)",
+			handler:     handlerDelete,
+		},
+		{
+			name:        "agg",
+			description: "Fetch stale feeds in a loop until interrupted",
+			args: []argSpec{
+				{name: "time_between_reqs", kind: durationValue, description: "interval between two rounds, e.g. 1m"},
+				{name: "concurrency", kind: intValue, optional: true, defaultValue: strconv.Itoa(defaultAggConcurrency),
+					description: "number of feeds fetched in parallel"},
+				{name: "batch_size", kind: intValue, optional: true, description: "feeds claimed per round (default concurrency)"},
+			},
+			flags: []flagSpec{
+				{name: "prune-every", kind: durationValue, description: "prune posts with the retention config this often (default retention.prune_interval)"},
+			},
+			handler: handlerAggCommand,
+		},
+		{
+			name:        "prune",
+			description: "Delete old posts with the retention config, saved posts are kept",
+			flags: []flagSpec{
+				{name: "max-age", kind: durationValue, description: "delete posts published longer ago (default retention.max_age)"},
+				{name: "max-posts", kind: intValue, description: "keep the newest posts of each feed (default retention.max_posts_per_feed)"},
+			},
+			handler: middlewareLoggedIn(handlerPrune),
+		},
+		{
+			name:        "serve",
+			description: "Serve users, feeds, follows and posts as a JSON api until interrupted",
+			flags: []flagSpec{
+				{name: "addr", placeholder: "host:port", defaultValue: defaultServeAddr, description: "address to listen on"},
+			},
+			handler: handlerServe,
+		},
+		{
+			name:        "addfeed",
+			description: "Add a RSS, Atom or JSON Feed, follow it and import its posts",
+			args: []argSpec{
+				{name: "url", description: "url of the feed, or of a website to search for its feeds"},
+				{name: "name", optional: true, description: "name of the feed, its title when omitted"},
+			},
+			handler: middlewareLoggedIn(handlerAddFeed),
+		},
+		{
+			name:        "feeds",
+			description: "List every feed with its health",
+			handler:     handlerFeeds,
+		},
+		{
+			name:        "enablefeed",
+			description: "Re-enable a feed you added, disabled after too many failures",
+			args:        []argSpec{feedUrlArg},
+			handler:     middlewareLoggedIn(handlerEnableFeed),
+		},
+		{
+			name:        "removefeed",
+			description: "Remove a feed you added, its follows go with it",
+			args:        []argSpec{feedUrlArg},
+			flags: []flagSpec{
+				{name: "posts", defaultValue: deletePostsPolicy, choices: removeFeedPolicies, description: "delete the posts, move them to another feed, or archive the feed to keep them"},
+				{name: "to", placeholder: "url", description: "feed receiving the posts with --posts move"},
+			},
+			handler: middlewareLoggedIn(handlerRemoveFeed),
+		},
+		{
+			name:        "renamefeed",
+			description: "Rename a feed you added",
+			args:        []argSpec{feedUrlArg, {name: "name", description: "new name of the feed"}},
+			handler:     middlewareLoggedIn(handlerRenameFeed),
+		},
+		{
+			name:        "setfeedurl",
+			description: "Move a feed you added to a new url, checked by fetching it",
+			args:        []argSpec{feedUrlArg, {name: "new_url", description: "new url of the feed"}},
+			handler:     middlewareLoggedIn(handlerSetFeedUrl),
+		},
+		{
+			name:        "follow",
+			description: "Follow an existing feed",
+			args:        []argSpec{feedUrlArg},
+			handler:     middlewareLoggedIn(handlerFollow),
+		},
+		{
+			name:        "following",
+			description: "List the feeds you follow",
+			handler:     middlewareLoggedIn(handlerFollowing),
+		},
+		{
+			name:        "unfollow",
+			description: "Stop following a feed",
+			args:        []argSpec{feedUrlArg},
+			handler:     middlewareLoggedIn(handlerUnFollow),
+		},
+		{
+			name:        "import",
+			description: "Follow the subscriptions of an OPML file, folders become categories",
+			args:        []argSpec{{name: "file", description: "OPML file to read"}},
+			handler:     middlewareLoggedIn(handlerImport),
+		},
+		{
+			name:        "export",
+			description: "Write the feeds you follow as OPML 2.0",
+			args:        []argSpec{{name: "file", optional: true, description: "file to write, stdout when omitted"}},
+			handler:     middlewareLoggedIn(handlerExport),
+		},
+		{
+			name:        "output",
+			description: "Write the posts of the feeds you follow as an RSS 2.0 or Atom feed",
+			args:        []argSpec{{name: "file", optional: true, description: "file to write, stdout when omitted"}},
+			flags: []flagSpec{
+				{name: "format", defaultValue: rssOutputFormat, choices: outputFormats, description: "feed format"},
+				{name: "category", placeholder: "category", description: "only feeds followed in this category or its sub folders"},
+				{name: "limit", kind: intValue, defaultValue: strconv.Itoa(defaultOutputLimit), description: "number of posts"},
+				{name: "base-url", placeholder: "url", defaultValue: "http://" + defaultServeAddr, description: "address of gator serve, the feed links to its output"},
+			},
+			handler: middlewareLoggedIn(handlerOutput),
+		},
+		{
+			name:        "browse",
+			description: "Show the posts of the feeds you follow",
+			args: []argSpec{
+				{name: "limit", kind: intValue, optional: true, description: "number of posts, same as --limit"},
+			},
+			flags:   browseFlags,
+			handler: middlewareLoggedIn(handlerBrowse),
+		},
+		{
+			name:        "tui",
+			description: "Full-screen reader: feeds with unread counts, posts and preview",
+			handler:     middlewareLoggedIn(handlerTui),
+		},
+		{
+			name:        "search",
+			description: "Full-text search of the stored posts, best match first",
+			args: []argSpec{
+				{name: "query", variadic: true, description: `words, "phrases", prefix*, -excluded, OR`},
+			},
+			flags: []flagSpec{
+				{name: "following", kind: boolValue, description: "only feeds you follow"},
+				{name: "feed", placeholder: "url", description: "only posts of this feed"},
+				{name: "limit", kind: intValue, defaultValue: strconv.Itoa(defaultSearchLimit), description: "number of posts"},
+			},
+			handler: middlewareLoggedIn(handlerSearch),
+		},
+		{
+			name:        "save",
+			description: "Save a post for later, saving again adds tags",
+			args: []argSpec{
+				postArg,
+				{name: "tags", variadic: true, optional: true, description: "tags of the saved post"},
+			},
+			handler: middlewareLoggedIn(handlerSave),
+		},
+		{
+			name:        "unsave",
+			description: "Remove a post from the saved posts",
+			args:        []argSpec{postArg},
+			handler:     middlewareLoggedIn(handlerUnsave),
+		},
+		{
+			name:        "saved",
+			description: "List the saved posts",
+			flags: []flagSpec{
+				{name: "tag", placeholder: "tag", description: "only posts with this tag"},
+			},
+			handler: middlewareLoggedIn(handlerSaved),
+		},
+		{
+			name:        "read",
+			description: "Mark a post as read",
+			args:        []argSpec{postArg},
+			handler:     middlewareLoggedIn(handlerRead),
+		},
+		{
+			name:        "unread",
+			description: "Mark a post as unread",
+			args:        []argSpec{postArg},
+			handler:     middlewareLoggedIn(handlerUnread),
+		},
+		{
+			name:        "markallread",
+			description: "Mark every post of the feeds you follow as read",
+			args: []argSpec{
+				{name: "feed_url", optional: true, description: "only mark the posts of this feed"},
+			},
+			handler: middlewareLoggedIn(handlerMarkAllRead),
+		},
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// nested OPML folders are stored as a single category joined with this separator
+const categorySeparator = "/"
+
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    struct {
+		Title       string `xml:"title"`
+		DateCreated string `xml:"dateCreated,omitempty"`
+	} `xml:"head"`
+	Body struct {
+		Outlines []OPMLOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type OPMLOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLUrl   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLUrl  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+type opmlSubscription struct {
+	Title    string
+	Url      string
+	Category string
+}
+
+func readOPML(r io.Reader) ([]opmlSubscription, error) {
+	var document OPML
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, fmt.Errorf("cannot parse opml: %v", err)
+	}
+
+	return flattenOutlines(document.Body.Outlines, ""), nil
+}
+
+/*
+*
+Outlines with an xmlUrl are subscriptions, the ones without are folders whose text becomes the category.
+*/
+func flattenOutlines(outlines []OPMLOutline, category string) []opmlSubscription {
+	var subscriptions []opmlSubscription
+	for _, outline := range outlines {
+		title := outline.Title
+		if title == "" {
+			title = outline.Text
+		}
+
+		if outline.XMLUrl != "" {
+			subscriptions = append(subscriptions, opmlSubscription{
+				Title:    title,
+				Url:      outline.XMLUrl,
+				Category: category,
+			})
+		}
+
+		if len(outline.Outlines) > 0 {
+			folder := category
+			if outline.XMLUrl == "" && title != "" {
+				folder = joinCategory(category, title)
+			}
+			subscriptions = append(subscriptions, flattenOutlines(outline.Outlines, folder)...)
+		}
+	}
+	return subscriptions
+}
+
+func joinCategory(parent, child string) string {
+	child = strings.TrimSpace(strings.ReplaceAll(child, categorySeparator, " "))
+	if parent == "" {
+		return child
+	}
+	return parent + categorySeparator + child
+}
+
+/*
+*
+This method writes the subscriptions as an OPML 2.0 document, categories become (nested) folders.
+*/
+func writeOPML(w io.Writer, title string, subscriptions []opmlSubscription, createdAt time.Time) error {
+	var document OPML
+	document.Version = "2.0"
+	document.Head.Title = title
+	document.Head.DateCreated = createdAt.Format(time.RFC1123Z)
+
+	sorted := make([]opmlSubscription, len(subscriptions))
+	copy(sorted, subscriptions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Category < sorted[j].Category
+	})
+
+	for _, subscription := range sorted {
+		outlines := &document.Body.Outlines
+		if subscription.Category != "" {
+			for _, folder := range strings.Split(subscription.Category, categorySeparator) {
+				outlines = folderOutlines(outlines, folder)
+			}
+		}
+
+		*outlines = append(*outlines, OPMLOutline{
+			Text:   subscription.Title,
+			Title:  subscription.Title,
+			Type:   "rss",
+			XMLUrl: subscription.Url,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("cannot write opml: %v", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func folderOutlines(outlines *[]OPMLOutline, name string) *[]OPMLOutline {
+	for idx := range *outlines {
+		if (*outlines)[idx].XMLUrl == "" && (*outlines)[idx].Text == name {
+			return &(*outlines)[idx].Outlines
+		}
+	}
+
+	*outlines = append(*outlines, OPMLOutline{Text: name, Title: name})
+	return &(*outlines)[len(*outlines)-1].Outlines
+}
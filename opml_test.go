@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadOPML_FoldersBecomeCategories(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+	<opml version="2.0">
+		<head><title>Subscriptions</title></head>
+		<body>
+			<outline text="Loose Feed" type="rss" xmlUrl="https://example.com/loose.xml"/>
+			<outline text="Tech">
+				<outline text="Go Blog" title="The Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom"/>
+				<outline text="Databases">
+					<outline text="Postgres" type="rss" xmlUrl="https://www.postgresql.org/news.rss"/>
+				</outline>
+			</outline>
+		</body>
+	</opml>`
+
+	subscriptions, err := readOPML(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("readOPML() returned unexpected error: %v", err)
+	}
+
+	expected := []opmlSubscription{
+		{Title: "Loose Feed", Url: "https://example.com/loose.xml", Category: ""},
+		{Title: "The Go Blog", Url: "https://go.dev/blog/feed.atom", Category: "Tech"},
+		{Title: "Postgres", Url: "https://www.postgresql.org/news.rss", Category: "Tech/Databases"},
+	}
+	if len(subscriptions) != len(expected) {
+		t.Fatalf("expected %d subscriptions, got: %d", len(expected), len(subscriptions))
+	}
+	for idx, want := range expected {
+		if subscriptions[idx] != want {
+			t.Errorf("expected subscription %+v, got: %+v", want, subscriptions[idx])
+		}
+	}
+}
+
+func TestWriteOPML_RoundTrip(t *testing.T) {
+	subscriptions := []opmlSubscription{
+		{Title: "Postgres", Url: "https://www.postgresql.org/news.rss", Category: "Tech/Databases"},
+		{Title: "Loose Feed", Url: "https://example.com/loose.xml"},
+		{Title: "The Go Blog", Url: "https://go.dev/blog/feed.atom", Category: "Tech"},
+	}
+
+	var buffer bytes.Buffer
+	if err := writeOPML(&buffer, "test", subscriptions, time.Now()); err != nil {
+		t.Fatalf("writeOPML() returned unexpected error: %v", err)
+	}
+	if !strings.Contains(buffer.String(), `<opml version="2.0">`) {
+		t.Errorf("expected an OPML 2.0 document, got: %s", buffer.String())
+	}
+
+	readBack, err := readOPML(&buffer)
+	if err != nil {
+		t.Fatalf("readOPML() returned unexpected error: %v", err)
+	}
+
+	found := make(map[opmlSubscription]bool)
+	for _, subscription := range readBack {
+		found[subscription] = true
+	}
+	for _, subscription := range subscriptions {
+		if !found[subscription] {
+			t.Errorf("expected %+v to survive the round trip, got: %+v", subscription, readBack)
+		}
+	}
+}
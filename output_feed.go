@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	rssOutputFormat    = "rss"
+	atomOutputFormat   = "atom"
+	defaultOutputLimit = 50
+	maxOutputLimit     = 500
+	// the api path of an output feed, the command uses it to link to the served feed
+	outputFeedPath = "/api/output/"
+)
+
+var outputFormats = []string{rssOutputFormat, atomOutputFormat}
+
+type outputStore interface {
+	GetFollowedPosts(ctx context.Context, arg database.GetFollowedPostsParams) ([]database.GetFollowedPostsRow, error)
+}
+
+/*
+*
+This method loads the newest posts of the feeds the user follows, only the ones filed under category
+(or one of its sub folders) when it is set, and wraps them in an RSS 2.0 document. The channel links to siteUrl,
+selfUrl (where the document is served) goes in an atom:link.
+*/
+func buildOutputFeed(ctx context.Context, store outputStore, user database.User, category string, limit int, siteUrl, selfUrl string) (*RSSFeed, error) {
+	if limit < 1 || limit > maxOutputLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxOutputLimit)
+	}
+
+	params := database.GetFollowedPostsParams{UserID: user.ID, PostLimit: int32(limit)}
+	if category != "" {
+		params.Category = sql.NullString{String: category, Valid: true}
+	}
+	posts, err := store.GetFollowedPosts(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get followed posts: %v", err)
+	}
+
+	feed := RSSFeed{Version: "2.0"}
+	feed.Channel.Title = fmt.Sprintf("%s in gator", user.Name)
+	feed.Channel.Description = fmt.Sprintf("Posts of the feeds %s follows", user.Name)
+	if category != "" {
+		feed.Channel.Title = fmt.Sprintf("%s in gator: %s", user.Name, category)
+		feed.Channel.Description = fmt.Sprintf("Posts of the feeds %s follows in %s", user.Name, category)
+	}
+	feed.Channel.Link = siteUrl
+	if selfUrl != "" {
+		feed.Channel.AtomLinks = []AtomLink{{Href: selfUrl, Rel: "self", Type: "application/rss+xml"}}
+	}
+
+	for _, post := range posts {
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       post.Title,
+			Link:        post.Url,
+			Description: post.Description,
+			PubDate:     post.PublishedAt.UTC().Format(time.RFC1123Z),
+			GUID:        postGUID(post.ID, post.Url),
+			Source:      &RSSSource{Url: post.FeedUrl, Name: post.FeedName},
+		})
+	}
+	return &feed, nil
+}
+
+// post urls are unique, so they make stable guids, the id is only a fallback for posts without one
+func postGUID(id uuid.UUID, postUrl string) string {
+	if postUrl != "" {
+		return postUrl
+	}
+	return "urn:uuid:" + id.String()
+}
+
+// the website of the output feeds, baseUrl is the scheme and host of the server
+func outputSiteUrl(baseUrl string) string {
+	return strings.TrimSuffix(baseUrl, "/") + "/"
+}
+
+// where the api serves the output feed of a category, baseUrl is the scheme and host of the server
+func outputFeedUrl(baseUrl, format, category string) string {
+	path := outputFeedPath + format
+	if category != "" {
+		for _, folder := range strings.Split(category, categorySeparator) {
+			path += "/" + url.PathEscape(folder)
+		}
+	}
+	return strings.TrimSuffix(baseUrl, "/") + path
+}
+
+/*
+*
+This method writes the output feed as RSS 2.0, or as Atom 1.0 when format is atom.
+*/
+func writeOutputFeed(w io.Writer, format string, feed *RSSFeed, author string, now time.Time) error {
+	var document any = feed
+	if format == atomOutputFormat {
+		document = feed.toAtomFeed(author, now)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("cannot write %s feed: %v", format, err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func outputContentType(format string) string {
+	if format == atomOutputFormat {
+		return "application/atom+xml; charset=utf-8"
+	}
+	return "application/rss+xml; charset=utf-8"
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testOutputStore(t *testing.T) (*fakeStore, database.User) {
+	t.Helper()
+	store := newFakeStore()
+	user, _ := store.addUser(t, "alice", false)
+
+	published := time.Date(2025, 2, 11, 9, 0, 0, 0, time.UTC)
+	for idx, category := range []string{"tech", "tech/go", "music"} {
+		feed := database.Feed{ID: uuid.New(), Name: category + " feed", Url: "https://example.com/" + category + ".xml"}
+		store.feeds = append(store.feeds, feed)
+		store.follows = append(store.follows, database.FeedFollow{FeedID: feed.ID, UserID: user.ID, Category: category})
+		store.posts = append(store.posts, database.GetPostByIdRow{
+			ID:          uuid.New(),
+			Title:       category + " & news",
+			Url:         "https://example.com/" + category,
+			Description: "<p>about " + category + "</p>",
+			PublishedAt: published.Add(-time.Duration(idx) * time.Hour),
+			FeedID:      feed.ID,
+		})
+	}
+	// posts of feeds alice does not follow stay out of her feed
+	store.posts = append(store.posts, database.GetPostByIdRow{ID: uuid.New(), Title: "other", FeedID: uuid.New(), PublishedAt: published})
+	return store, user
+}
+
+func TestBuildOutputFeed_Category(t *testing.T) {
+	store, user := testOutputStore(t)
+
+	feed, err := buildOutputFeed(context.Background(), store, user, "tech", 10, "http://localhost:8080/", "http://localhost:8080/api/output/rss/tech")
+	if err != nil {
+		t.Fatalf("buildOutputFeed() returned unexpected error: %v", err)
+	}
+	if len(feed.Channel.Item) != 2 {
+		t.Fatalf("expected the posts of tech and tech/go, got: %d", len(feed.Channel.Item))
+	}
+	if feed.Channel.Link != "http://localhost:8080/" || selfLink(feed.Channel.AtomLinks) != "http://localhost:8080/api/output/rss/tech" {
+		t.Errorf("expected the site as link and the output url as self link, got: %s %+v", feed.Channel.Link, feed.Channel.AtomLinks)
+	}
+	item := feed.Channel.Item[0]
+	if item.Title != "tech & news" || item.GUID != item.Link || item.Source == nil || item.Source.Name != "tech feed" {
+		t.Errorf("unexpected first item: %+v", item)
+	}
+
+	all, err := buildOutputFeed(context.Background(), store, user, "", 2, "", "")
+	if err != nil {
+		t.Fatalf("buildOutputFeed() returned unexpected error: %v", err)
+	}
+	if len(all.Channel.Item) != 2 || !strings.Contains(all.Channel.Title, "alice") {
+		t.Errorf("expected the 2 newest posts of alice, got: %+v", all.Channel)
+	}
+
+	if _, err := buildOutputFeed(context.Background(), store, user, "", 0, "", ""); err == nil {
+		t.Errorf("expected an error for a zero limit")
+	}
+}
+
+func TestWriteOutputFeed_RoundTrip(t *testing.T) {
+	store, user := testOutputStore(t)
+	feed, err := buildOutputFeed(context.Background(), store, user, "", 10, "http://localhost:8080/", "http://localhost:8080/api/output/rss")
+	if err != nil {
+		t.Fatalf("buildOutputFeed() returned unexpected error: %v", err)
+	}
+
+	for _, format := range outputFormats {
+		var buffer bytes.Buffer
+		if err := writeOutputFeed(&buffer, format, feed, user.Name, time.Now()); err != nil {
+			t.Fatalf("writeOutputFeed(%s) returned unexpected error: %v", format, err)
+		}
+
+		parsed, err := parseFeed(buffer.Bytes())
+		if err != nil {
+			t.Fatalf("parseFeed(%s) returned unexpected error: %v\n%s", format, err, buffer.String())
+		}
+		if len(parsed.Channel.Item) != 3 {
+			t.Fatalf("%s: expected 3 items, got: %d", format, len(parsed.Channel.Item))
+		}
+		first := parsed.Channel.Item[0]
+		if first.Title != "tech & news" || first.Link != "https://example.com/tech" || first.Description != "<p>about tech</p>" {
+			t.Errorf("%s: unexpected first item: %+v", format, first)
+		}
+		if _, err := time.Parse(time.RFC1123Z, first.PubDate); err != nil {
+			t.Errorf("%s: expected an RFC1123Z date, got: %s", format, first.PubDate)
+		}
+	}
+}
+
+func TestWriteOutputFeed_Atom(t *testing.T) {
+	store, user := testOutputStore(t)
+	feed, err := buildOutputFeed(context.Background(), store, user, "", 10, "http://localhost:8080/", "http://localhost:8080/api/output/atom")
+	if err != nil {
+		t.Fatalf("buildOutputFeed() returned unexpected error: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := writeOutputFeed(&buffer, atomOutputFormat, feed, user.Name, time.Now()); err != nil {
+		t.Fatalf("writeOutputFeed() returned unexpected error: %v", err)
+	}
+	document := buffer.String()
+	for _, want := range []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom">`,
+		`<id>http://localhost:8080/api/output/atom</id>`,
+		`<updated>2025-02-11T09:00:00Z</updated>`,
+		`<name>alice</name>`,
+		`<id>https://example.com/tech/go</id>`,
+	} {
+		if !strings.Contains(document, want) {
+			t.Errorf("expected the atom document to contain %s, got:\n%s", want, document)
+		}
+	}
+}
+
+func TestOutputFeedUrl(t *testing.T) {
+	if got := outputFeedUrl("http://localhost:8080/", "rss", "tech/web dev"); got != "http://localhost:8080/api/output/rss/tech/web%20dev" {
+		t.Errorf("unexpected output feed url: %s", got)
+	}
+}
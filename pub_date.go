@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var errNoPubDate = errors.New("item has no published date")
+
+/*
+Layouts seen in the wild for RSS pubDate (RFC822 and its many variations), Atom / JSON Feed (RFC3339)
+and Dublin Core dc:date (W3CDTF), in the order we try them.
+*/
+var pubDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.UnixDate,
+	time.RubyDate,
+	time.ANSIC,
+}
+
+/*
+*
+This method returns the first candidate that parses with one of the known layouts.
+Empty candidates are skipped, and errNoPubDate is returned when every candidate is empty.
+*/
+func parsePubDate(candidates ...string) (time.Time, error) {
+	var lastValue string
+	for _, candidate := range candidates {
+		value := strings.TrimSpace(candidate)
+		if value == "" {
+			continue
+		}
+		lastValue = value
+
+		// RFC822 allows "UT" as a zone, which Go only knows as "UTC".
+		if strings.HasSuffix(value, " UT") {
+			value += "C"
+		}
+
+		for _, layout := range pubDateLayouts {
+			if parsed, err := time.Parse(layout, value); err == nil {
+				return parsed, nil
+			}
+		}
+	}
+
+	if lastValue == "" {
+		return time.Time{}, errNoPubDate
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date format %q", lastValue)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParsePubDate_KnownLayouts(t *testing.T) {
+	expected := time.Date(2021, time.September, 6, 12, 0, 0, 0, time.UTC)
+
+	values := []string{
+		"Mon, 06 Sep 2021 12:00:00 +0000",
+		"Mon, 06 Sep 2021 12:00:00 GMT",
+		"Mon, 6 Sep 2021 12:00:00 +0000",
+		"Mon, 06 Sep 2021 12:00:00 UT",
+		"06 Sep 2021 12:00:00 +0000",
+		"2021-09-06T12:00:00Z",
+		"2021-09-06T14:00:00+02:00",
+		"2021-09-06T12:00:00.000Z",
+	}
+
+	for _, value := range values {
+		parsed, err := parsePubDate(value)
+		if err != nil {
+			t.Errorf("parsePubDate(%q) returned unexpected error: %v", value, err)
+			continue
+		}
+		if !parsed.Equal(expected) {
+			t.Errorf("parsePubDate(%q) expected %v, got: %v", value, expected, parsed)
+		}
+	}
+}
+
+func TestParsePubDate_FallsBackToNextCandidate(t *testing.T) {
+	parsed, err := parsePubDate("", "2021-09-06")
+	if err != nil {
+		t.Fatalf("parsePubDate() returned unexpected error: %v", err)
+	}
+	if parsed.Year() != 2021 || parsed.Month() != time.September || parsed.Day() != 6 {
+		t.Errorf("expected dc:date to be used, got: %v", parsed)
+	}
+}
+
+func TestParsePubDate_Missing(t *testing.T) {
+	_, err := parsePubDate("", "  ")
+	if !errors.Is(err, errNoPubDate) {
+		t.Errorf("expected errNoPubDate, got: %v", err)
+	}
+}
+
+func TestParsePubDate_Unrecognized(t *testing.T) {
+	_, err := parsePubDate("last tuesday")
+	if err == nil || errors.Is(err, errNoPubDate) {
+		t.Errorf("expected unrecognized format error, got: %v", err)
+	}
+}
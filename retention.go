@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bootDevGoRss/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+/*
+retentionPolicy is the parsed retention section of the config, a zero field disables that rule.
+*/
+type retentionPolicy struct {
+	maxAge          time.Duration
+	maxPostsPerFeed int64
+	// agg prunes this often, zero means agg leaves pruning to the prune command
+	pruneInterval time.Duration
+}
+
+type pruneStore interface {
+	PruneExpiredPosts(ctx context.Context, publishedBefore time.Time) (int64, error)
+	PruneExcessPosts(ctx context.Context, maxPostsPerFeed int64) (int64, error)
+}
+
+type pruneResult struct {
+	expired int64
+	excess  int64
+}
+
+func (r pruneResult) String() string {
+	return fmt.Sprintf("%d post(s) past the max age and %d beyond the per feed limit removed", r.expired, r.excess)
+}
+
+/*
+*
+This method reads the retention section of the config file, durations use the Go syntax (e.g. 720h).
+*/
+func newRetentionPolicy(cfg config.RetentionConfig) (retentionPolicy, error) {
+	var policy retentionPolicy
+	if cfg.MaxAge != "" {
+		parsed, err := time.ParseDuration(cfg.MaxAge)
+		if err != nil || parsed <= 0 {
+			return policy, fmt.Errorf("invalid retention max_age %q, expected a positive duration", cfg.MaxAge)
+		}
+		policy.maxAge = parsed
+	}
+
+	if cfg.MaxPostsPerFeed < 0 {
+		return policy, fmt.Errorf("invalid retention max_posts_per_feed %d", cfg.MaxPostsPerFeed)
+	}
+	policy.maxPostsPerFeed = cfg.MaxPostsPerFeed
+
+	if cfg.PruneInterval != "" {
+		parsed, err := time.ParseDuration(cfg.PruneInterval)
+		if err != nil || parsed <= 0 {
+			return policy, fmt.Errorf("invalid retention prune_interval %q, expected a positive duration", cfg.PruneInterval)
+		}
+		policy.pruneInterval = parsed
+	}
+	return policy, nil
+}
+
+func (p retentionPolicy) keepsEverything() bool {
+	return p.maxAge == 0 && p.maxPostsPerFeed == 0
+}
+
+// posts published before this are past the max age, the zero time when there is no max age
+func (p retentionPolicy) expiredBefore(now time.Time) time.Time {
+	if p.maxAge == 0 {
+		return time.Time{}
+	}
+	return now.Add(-p.maxAge)
+}
+
+/*
+*
+This method tells which items of a fetched feed rank within the per feed limit, newest first with ties kept in
+document order. The items beyond it would be pruned right away, so the scraper does not import them.
+*/
+func (p retentionPolicy) withinFeedLimit(publishedTimes []time.Time) []bool {
+	kept := make([]bool, len(publishedTimes))
+	order := make([]int, len(publishedTimes))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return publishedTimes[order[i]].After(publishedTimes[order[j]])
+	})
+	for rank, idx := range order {
+		kept[idx] = p.maxPostsPerFeed == 0 || int64(rank) < p.maxPostsPerFeed
+	}
+	return kept
+}
+
+/*
+*
+This method deletes the posts past the max age, then the oldest ones of every feed beyond the per feed limit.
+Saved posts are skipped by both rules and do not count towards the limit.
+*/
+func prunePosts(ctx context.Context, store pruneStore, policy retentionPolicy, now time.Time) (pruneResult, error) {
+	var result pruneResult
+	if policy.keepsEverything() {
+		return result, errors.New("no retention configured, set max_age or max_posts_per_feed")
+	}
+
+	if policy.maxAge > 0 {
+		expired, err := store.PruneExpiredPosts(ctx, policy.expiredBefore(now))
+		if err != nil {
+			return result, fmt.Errorf("cannot prune expired posts: %v", err)
+		}
+		result.expired = expired
+	}
+
+	if policy.maxPostsPerFeed > 0 {
+		excess, err := store.PruneExcessPosts(ctx, policy.maxPostsPerFeed)
+		if err != nil {
+			return result, fmt.Errorf("cannot prune excess posts: %v", err)
+		}
+		result.excess = excess
+	}
+	return result, nil
+}
+
+/*
+*
+This method runs prunePosts every policy.pruneInterval until ctx is cancelled, starting right away.
+Failures are reported and retried on the next tick, they do not stop agg.
+*/
+func runPruneJob(ctx context.Context, store pruneStore, policy retentionPolicy, stats *aggStats) {
+	ticker := time.NewTicker(policy.pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := prunePosts(ctx, store, policy, time.Now())
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Printf("Prune failed, retrying in %s: %v\n", policy.pruneInterval, err)
+		} else {
+			stats.postsPruned.Add(result.expired + result.excess)
+			fmt.Printf("Pruned posts: %s\n", result)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bootDevGoRss/internal/config"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// records the arguments of the prune queries
+type fakePruneStore struct {
+	publishedBefore time.Time
+	maxPostsPerFeed int64
+	err             error
+}
+
+func (f *fakePruneStore) PruneExpiredPosts(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	f.publishedBefore = publishedBefore
+	return 3, f.err
+}
+
+func (f *fakePruneStore) PruneExcessPosts(ctx context.Context, maxPostsPerFeed int64) (int64, error) {
+	f.maxPostsPerFeed = maxPostsPerFeed
+	return 2, f.err
+}
+
+func TestNewRetentionPolicy(t *testing.T) {
+	policy, err := newRetentionPolicy(config.RetentionConfig{MaxAge: "720h", MaxPostsPerFeed: 100, PruneInterval: "1h"})
+	if err != nil {
+		t.Fatalf("newRetentionPolicy() returned unexpected error: %v", err)
+	}
+	expected := retentionPolicy{maxAge: 720 * time.Hour, maxPostsPerFeed: 100, pruneInterval: time.Hour}
+	if policy != expected {
+		t.Errorf("expected %+v, got: %+v", expected, policy)
+	}
+
+	empty, err := newRetentionPolicy(config.RetentionConfig{})
+	if err != nil || !empty.keepsEverything() {
+		t.Errorf("expected an empty config to keep everything, got: %+v %v", empty, err)
+	}
+
+	invalid := []config.RetentionConfig{{MaxAge: "a month"}, {MaxAge: "-1h"}, {MaxPostsPerFeed: -1}, {PruneInterval: "0s"}}
+	for _, cfg := range invalid {
+		if _, err := newRetentionPolicy(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestPrunePosts(t *testing.T) {
+	now := time.Date(2025, 2, 11, 9, 0, 0, 0, time.UTC)
+	store := &fakePruneStore{}
+
+	result, err := prunePosts(context.Background(), store, retentionPolicy{maxAge: 24 * time.Hour, maxPostsPerFeed: 50}, now)
+	if err != nil {
+		t.Fatalf("prunePosts() returned unexpected error: %v", err)
+	}
+	if result != (pruneResult{expired: 3, excess: 2}) {
+		t.Errorf("expected the counts of both rules, got: %+v", result)
+	}
+	if !store.publishedBefore.Equal(now.Add(-24*time.Hour)) || store.maxPostsPerFeed != 50 {
+		t.Errorf("unexpected prune arguments: %+v", store)
+	}
+
+	onlyLimit := &fakePruneStore{}
+	result, err = prunePosts(context.Background(), onlyLimit, retentionPolicy{maxPostsPerFeed: 10}, now)
+	if err != nil || result.expired != 0 || !onlyLimit.publishedBefore.IsZero() {
+		t.Errorf("expected only the per feed limit to run, got: %+v %v", result, err)
+	}
+
+	if _, err := prunePosts(context.Background(), store, retentionPolicy{}, now); err == nil {
+		t.Errorf("expected an error without retention")
+	}
+	failing := &fakePruneStore{err: errors.New("connection refused")}
+	if _, err := prunePosts(context.Background(), failing, retentionPolicy{maxAge: time.Hour}, now); err == nil {
+		t.Errorf("expected the store error to be returned")
+	}
+}
+
+func TestRetentionPolicy_ExpiredBefore(t *testing.T) {
+	now := time.Now()
+	if got := (retentionPolicy{}).expiredBefore(now); !got.IsZero() {
+		t.Errorf("expected no cutoff without max age, got: %v", got)
+	}
+	if got := (retentionPolicy{maxAge: time.Hour}).expiredBefore(now); !got.Equal(now.Add(-time.Hour)) {
+		t.Errorf("expected a cutoff one hour ago, got: %v", got)
+	}
+}
+
+func TestRetentionPolicy_WithinFeedLimit(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	// documents are usually newest first, but not always
+	publishedTimes := []time.Time{now.Add(-2 * time.Hour), now, now.Add(-3 * time.Hour), now.Add(-time.Hour)}
+
+	got := (retentionPolicy{maxPostsPerFeed: 2}).withinFeedLimit(publishedTimes)
+	expected := []bool{false, true, false, true}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got: %v", expected, got)
+	}
+
+	got = (retentionPolicy{}).withinFeedLimit(publishedTimes)
+	if slices.Contains(got, false) {
+		t.Errorf("expected every item without a limit, got: %v", got)
+	}
+}
+
+/*
+*
+The prune queries only run against postgres, so this checks their text: both must leave saved posts alone.
+*/
+func TestPruneQueries_SkipSavedPosts(t *testing.T) {
+	source, err := os.ReadFile("sql/queries/post.sql")
+	if err != nil {
+		t.Fatalf("cannot read the post queries: %v", err)
+	}
+
+	queries := make(map[string]string)
+	for _, block := range strings.Split(string(source), "-- name: ")[1:] {
+		name, _, _ := strings.Cut(block, " ")
+		queries[name] = block
+	}
+	for _, name := range []string{"PruneExpiredPosts", "PruneExcessPosts"} {
+		query, ok := queries[name]
+		if !ok {
+			t.Fatalf("query %s is not declared", name)
+		}
+		if !strings.Contains(query, "not exists (select 1 from saved_posts where saved_posts.post_id = posts.id)") {
+			t.Errorf("expected %s to skip saved posts, got: %s", name, query)
+		}
+	}
+}
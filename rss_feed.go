@@ -1,53 +1,220 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
+	"io"
 	"net/http"
+	"time"
 )
 
+// same limit as the default http client
+const maxRedirects = 10
+
+/*
+The same types decode the fetched feeds and encode the output feed of the followed posts,
+the fields only written by gator are omitempty.
+*/
 type RSSFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr,omitempty"`
 	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
+		Title string `xml:"title"`
+		// atom:link, listed before link so it does not overwrite the website link when decoding
+		AtomLinks   []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+		Link        string     `xml:"link"`
+		Description string     `xml:"description"`
+		Image       *RSSImage  `xml:"image,omitempty"`
+		Item        []RSSItem  `xml:"item"`
 	} `xml:"channel"`
 }
 
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	Description string     `xml:"description"`
+	PubDate     string     `xml:"pubDate"`
+	DcDate      string     `xml:"http://purl.org/dc/elements/1.1/ date,omitempty"`
+	GUID        string     `xml:"guid,omitempty"`
+	Source      *RSSSource `xml:"source,omitempty"`
+}
+
+type RSSImage struct {
+	Url   string `xml:"url"`
+	Title string `xml:"title,omitempty"`
+	Link  string `xml:"link,omitempty"`
+}
+
+// the feed an aggregated item comes from
+type RSSSource struct {
+	Url  string `xml:"url,attr"`
+	Name string `xml:",chardata"`
 }
 
-func fetchFeed(ctx context.Context, feedUrl string) (*RSSFeed, error) {
+/*
+Validators returned by the publisher, sent back on the next fetch so unchanged feeds answer 304 Not Modified.
+*/
+type feedCache struct {
+	ETag         string
+	LastModified string
+}
+
+type fetchResult struct {
+	// nil when the publisher answered 304 Not Modified
+	Feed        *RSSFeed
+	Cache       feedCache
+	NotModified bool
+	// end of the leading run of permanent redirects (301/308), kept even when a 302 follows it,
+	// this is where the feed lives now
+	PermanentUrl string
+}
+
+func (c *feedClient) fetchFeed(ctx context.Context, feedUrl string, cache feedCache) (*fetchResult, error) {
 	req, err := http.NewRequest("GET", feedUrl, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to fetch feed data %v", err)
 	}
-	req.Header.Set("User-Agent", "gator")
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8")
+	if cache.ETag != "" {
+		req.Header.Set("If-None-Match", cache.ETag)
+	}
+	if cache.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cache.LastModified)
+	}
+
+	// Copy the client so the redirect bookkeeping below stays local to this request.
+	client := *c.httpClient
+	permanentUrl := ""
+	onlyPermanent := true
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		status := req.Response.StatusCode
+		if onlyPermanent && (status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect) {
+			permanentUrl = req.URL.String()
+		} else {
+			onlyPermanent = false
+		}
+		return nil
+	}
 
-	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var feed RSSFeed
-	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+	if resp.StatusCode == http.StatusNotModified {
+		return &fetchResult{Cache: cache, NotModified: true, PermanentUrl: permanentUrl}, nil
+	}
+	if err := checkFeedResponse(resp, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// Read one byte past the limit so an oversized feed is rejected instead of silently truncated.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBodyBytes+1))
+	if err != nil {
 		return nil, fmt.Errorf("Failed to fetch feed data %v", err)
 	}
+	if int64(len(data)) > c.maxBodyBytes {
+		return nil, &fetchError{
+			kind:       errFeedTooLarge,
+			Url:        resp.Request.URL.String(),
+			StatusCode: resp.StatusCode,
+			cause:      fmt.Errorf("body larger than %d bytes", c.maxBodyBytes),
+		}
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	feed, err := decodeFeed(contentType, data)
+	if err != nil {
+		return nil, &fetchError{
+			kind:        errNotAFeed,
+			Url:         resp.Request.URL.String(),
+			StatusCode:  resp.StatusCode,
+			ContentType: contentType,
+			body:        data,
+			cause:       err,
+		}
+	}
 
 	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
 	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
-	for _, item := range feed.Channel.Item {
-		item.Title = html.UnescapeString(item.Title)
-		item.Description = html.UnescapeString(item.Description)
+	for idx := range feed.Channel.Item {
+		feed.Channel.Item[idx].Title = html.UnescapeString(feed.Channel.Item[idx].Title)
+		feed.Channel.Item[idx].Description = html.UnescapeString(feed.Channel.Item[idx].Description)
+	}
+
+	return &fetchResult{
+		Feed: feed,
+		Cache: feedCache{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+		PermanentUrl: permanentUrl,
+	}, nil
+}
+
+/*
+*
+This method picks the JSON Feed decoder when the response says it is json (or the body looks like json),
+otherwise the document goes through the XML decoder.
+*/
+func decodeFeed(contentType string, data []byte) (*RSSFeed, error) {
+	if isJSONContentType(contentType) || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return parseJSONFeed(data)
+	}
+
+	return parseFeed(data)
+}
+
+/*
+*
+This method looks at the root element of the document to decide between RSS (<rss>) and Atom (<feed>).
+*/
+func parseFeed(data []byte) (*RSSFeed, error) {
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
 	}
 
-	return &feed, nil
+	switch root {
+	case "rss":
+		var feed RSSFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, err
+		}
+		return &feed, nil
+	case "feed":
+		var atom AtomFeed
+		if err := xml.Unmarshal(data, &atom); err != nil {
+			return nil, err
+		}
+		return atom.toRSSFeed(), nil
+	default:
+		return nil, fmt.Errorf("unsupported feed format <%s>", root)
+	}
+}
+
+func rootElement(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("document has no root element")
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
 }
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bootDevGoRss/internal/config"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testFeedClient(t *testing.T) *feedClient {
+	t.Helper()
+
+	client, err := newFeedClient(config.FetchConfig{})
+	if err != nil {
+		t.Fatalf("newFeedClient() returned unexpected error: %v", err)
+	}
+	return client
+}
+
+func TestParseFeed_RSS(t *testing.T) {
+	data := []byte(`<rss version="2.0"><channel>
+		<title>RSS Feed Example</title>
+		<link>https://www.example.com</link>
+		<item>
+			<title>First Article</title>
+			<link>https://www.example.com/article1</link>
+			<description>First content</description>
+			<pubDate>Mon, 06 Sep 2021 12:00:00 +0000</pubDate>
+		</item>
+	</channel></rss>`)
+
+	feed, err := parseFeed(data)
+	if err != nil {
+		t.Fatalf("parseFeed() returned unexpected error: %v", err)
+	}
+
+	if feed.Channel.Title != "RSS Feed Example" {
+		t.Errorf("expected title 'RSS Feed Example', got: %s", feed.Channel.Title)
+	}
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("expected 1 item, got: %d", len(feed.Channel.Item))
+	}
+	if feed.Channel.Item[0].Link != "https://www.example.com/article1" {
+		t.Errorf("expected item link 'https://www.example.com/article1', got: %s", feed.Channel.Item[0].Link)
+	}
+}
+
+func TestParseFeed_Atom(t *testing.T) {
+	data := []byte(`<?xml version="1.0" encoding="utf-8"?>
+	<feed xmlns="http://www.w3.org/2005/Atom">
+		<title>Atom Example</title>
+		<link href="https://www.example.com/feed.atom" rel="self"/>
+		<link href="https://www.example.com/"/>
+		<entry>
+			<title>First Entry</title>
+			<link href="https://www.example.com/edit/1" rel="edit"/>
+			<link href="https://www.example.com/entry1" rel="alternate"/>
+			<content type="html">Entry content</content>
+			<updated>2021-09-06T12:00:00Z</updated>
+		</entry>
+	</feed>`)
+
+	feed, err := parseFeed(data)
+	if err != nil {
+		t.Fatalf("parseFeed() returned unexpected error: %v", err)
+	}
+
+	if feed.Channel.Title != "Atom Example" {
+		t.Errorf("expected title 'Atom Example', got: %s", feed.Channel.Title)
+	}
+	if feed.Channel.Link != "https://www.example.com/" {
+		t.Errorf("expected channel link 'https://www.example.com/', got: %s", feed.Channel.Link)
+	}
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("expected 1 item, got: %d", len(feed.Channel.Item))
+	}
+
+	item := feed.Channel.Item[0]
+	if item.Link != "https://www.example.com/entry1" {
+		t.Errorf("expected alternate link 'https://www.example.com/entry1', got: %s", item.Link)
+	}
+	if item.Description != "Entry content" {
+		t.Errorf("expected content as description, got: %s", item.Description)
+	}
+	if item.PubDate != "Mon, 06 Sep 2021 12:00:00 +0000" {
+		t.Errorf("expected updated date converted to RFC1123Z, got: %s", item.PubDate)
+	}
+}
+
+func TestParseFeed_AtomXHTMLContent(t *testing.T) {
+	data := []byte(`<feed xmlns="http://www.w3.org/2005/Atom">
+		<title>Atom Example</title>
+		<entry>
+			<title>Markup</title>
+			<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Hello <b>world</b></p></div></content>
+			<updated>2021-09-06T12:00:00Z</updated>
+		</entry>
+		<entry>
+			<title>Escaped</title>
+			<summary type="html"><![CDATA[<p>Summary</p>]]></summary>
+			<content type="html">&lt;p&gt;Long content&lt;/p&gt;</content>
+			<updated>2021-09-06T12:00:00Z</updated>
+		</entry>
+	</feed>`)
+
+	feed, err := parseFeed(data)
+	if err != nil {
+		t.Fatalf("parseFeed() returned unexpected error: %v", err)
+	}
+	if len(feed.Channel.Item) != 2 {
+		t.Fatalf("expected 2 items, got: %d", len(feed.Channel.Item))
+	}
+	if got := feed.Channel.Item[0].Description; got != "<p>Hello <b>world</b></p>" {
+		t.Errorf("expected the xhtml markup as description, got: %q", got)
+	}
+	if got := feed.Channel.Item[1].Description; got != "<p>Summary</p>" {
+		t.Errorf("expected the summary as description, got: %q", got)
+	}
+}
+
+func TestParseFeed_RSSAtomSelfLink(t *testing.T) {
+	data := []byte(`<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+		<title>RSS Feed Example</title>
+		<link>https://www.example.com</link>
+		<atom:link href="https://www.example.com/feed.xml" rel="self" type="application/rss+xml"/>
+	</channel></rss>`)
+
+	feed, err := parseFeed(data)
+	if err != nil {
+		t.Fatalf("parseFeed() returned unexpected error: %v", err)
+	}
+	if feed.Channel.Link != "https://www.example.com" {
+		t.Errorf("expected the website link to survive the atom:link, got: %q", feed.Channel.Link)
+	}
+	if got := selfLink(feed.Channel.AtomLinks); got != "https://www.example.com/feed.xml" {
+		t.Errorf("expected the self link, got: %q", got)
+	}
+}
+
+func TestParseFeed_UnsupportedRoot(t *testing.T) {
+	_, err := parseFeed([]byte(`<html><body>not a feed</body></html>`))
+	if err == nil {
+		t.Error("expected error for html document, got nil")
+	}
+}
+
+func TestDecodeFeed_JSONFeed(t *testing.T) {
+	data := []byte(`{
+		"version": "https://jsonfeed.org/version/1.1",
+		"title": "JSON Example",
+		"home_page_url": "https://www.example.com/",
+		"items": [
+			{
+				"id": "1",
+				"url": "https://www.example.com/post1",
+				"title": "First Post",
+				"content_html": "<p>Post content</p>",
+				"date_published": "2021-09-06T12:00:00Z"
+			}
+		]
+	}`)
+
+	feed, err := decodeFeed("application/feed+json; charset=utf-8", data)
+	if err != nil {
+		t.Fatalf("decodeFeed() returned unexpected error: %v", err)
+	}
+
+	if feed.Channel.Title != "JSON Example" {
+		t.Errorf("expected title 'JSON Example', got: %s", feed.Channel.Title)
+	}
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("expected 1 item, got: %d", len(feed.Channel.Item))
+	}
+
+	item := feed.Channel.Item[0]
+	if item.Link != "https://www.example.com/post1" {
+		t.Errorf("expected item link 'https://www.example.com/post1', got: %s", item.Link)
+	}
+	if item.Description != "<p>Post content</p>" {
+		t.Errorf("expected content_html as description, got: %s", item.Description)
+	}
+	if item.PubDate != "Mon, 06 Sep 2021 12:00:00 +0000" {
+		t.Errorf("expected date converted to RFC1123Z, got: %s", item.PubDate)
+	}
+}
+
+func TestDecodeFeed_SniffsJSONWithoutContentType(t *testing.T) {
+	data := []byte(`{"version": "https://jsonfeed.org/version/1", "title": "Sniffed", "items": []}`)
+
+	feed, err := decodeFeed("text/plain", data)
+	if err != nil {
+		t.Fatalf("decodeFeed() returned unexpected error: %v", err)
+	}
+	if feed.Channel.Title != "Sniffed" {
+		t.Errorf("expected title 'Sniffed', got: %s", feed.Channel.Title)
+	}
+}
+
+func TestDecodeFeed_RejectsPlainJSON(t *testing.T) {
+	_, err := decodeFeed("application/json", []byte(`{"hello": "world"}`))
+	if err == nil {
+		t.Error("expected error for json without feed version, got nil")
+	}
+}
+
+func TestFetchFeed_ConditionalGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 06 Sep 2021 12:00:00 GMT")
+		w.Write([]byte(`<rss><channel><title>Cached</title></channel></rss>`))
+	}))
+	defer server.Close()
+
+	first, err := testFeedClient(t).fetchFeed(context.Background(), server.URL, feedCache{})
+	if err != nil {
+		t.Fatalf("fetchFeed() returned unexpected error: %v", err)
+	}
+	if first.NotModified || first.Feed == nil {
+		t.Fatalf("expected a full response on first fetch, got: %+v", first)
+	}
+	if first.Cache.ETag != `"v1"` || first.Cache.LastModified != "Mon, 06 Sep 2021 12:00:00 GMT" {
+		t.Errorf("expected validators to be returned, got: %+v", first.Cache)
+	}
+
+	second, err := testFeedClient(t).fetchFeed(context.Background(), server.URL, first.Cache)
+	if err != nil {
+		t.Fatalf("fetchFeed() returned unexpected error: %v", err)
+	}
+	if !second.NotModified {
+		t.Error("expected 304 Not Modified on second fetch")
+	}
+	if second.Cache != first.Cache {
+		t.Errorf("expected validators to be kept on 304, got: %+v", second.Cache)
+	}
+}
+
+func TestFetchFeed_TypedErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/busy":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<!DOCTYPE html><html><body>hello</body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	expected := map[string]error{
+		"/missing": errFeedNotFound,
+		"/gone":    errFeedGone,
+		"/busy":    errFeedRateLimited,
+		"/broken":  errFeedServerError,
+		"/page":    errNotAFeed,
+	}
+
+	for path, want := range expected {
+		_, err := testFeedClient(t).fetchFeed(context.Background(), server.URL+path, feedCache{})
+		if !errors.Is(err, want) {
+			t.Errorf("fetchFeed(%s) expected %v, got: %v", path, want, err)
+		}
+	}
+
+	_, err := testFeedClient(t).fetchFeed(context.Background(), server.URL+"/busy", feedCache{})
+	var fetchErr *fetchError
+	if !errors.As(err, &fetchErr) || fetchErr.RetryAfter != 2*time.Minute {
+		t.Errorf("expected Retry-After of 2m, got: %v", err)
+	}
+}
+
+func TestFetchFeed_PermanentRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/temporary":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		case "/chain":
+			http.Redirect(w, r, "/temporary", http.StatusMovedPermanently)
+		case "/new":
+			w.Write([]byte(`<rss><channel><title>Moved</title></channel></rss>`))
+		}
+	}))
+	defer server.Close()
+
+	expected := map[string]string{
+		"/old":       server.URL + "/new",
+		"/temporary": "",
+		"/chain":     server.URL + "/temporary",
+	}
+
+	for path, want := range expected {
+		result, err := testFeedClient(t).fetchFeed(context.Background(), server.URL+path, feedCache{})
+		if err != nil {
+			t.Fatalf("fetchFeed(%s) returned unexpected error: %v", path, err)
+		}
+		if result.PermanentUrl != want {
+			t.Errorf("fetchFeed(%s) expected permanent url '%s', got: '%s'", path, want, result.PermanentUrl)
+		}
+	}
+}
+
+func TestFetchFeed_LimitsBodyAndSendsUserAgent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "gator-test" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`<rss><channel><title>A title longer than the limit</title></channel></rss>`))
+	}))
+	defer server.Close()
+
+	client, err := newFeedClient(config.FetchConfig{UserAgent: "gator-test", MaxBodyBytes: 32})
+	if err != nil {
+		t.Fatalf("newFeedClient() returned unexpected error: %v", err)
+	}
+
+	_, err = client.fetchFeed(context.Background(), server.URL, feedCache{})
+	if !errors.Is(err, errFeedTooLarge) {
+		t.Errorf("expected errFeedTooLarge, got: %v", err)
+	}
+}
+
+func TestNewFeedClient_InvalidTimeout(t *testing.T) {
+	_, err := newFeedClient(config.FetchConfig{Timeout: "soon"})
+	if err == nil {
+		t.Error("expected error for invalid timeout, got nil")
+	}
+}
+
+func TestDecodeFeed_ChannelImage(t *testing.T) {
+	documents := map[string]string{
+		"rss": `<rss version="2.0"><channel><title>Blog</title><link>https://example.com/</link>
+			<image><url>https://example.com/logo.png</url><title>Blog</title><link>https://example.com/</link></image>
+		</channel></rss>`,
+		"atom": `<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title>
+			<icon>https://example.com/favicon.ico</icon><logo>https://example.com/logo.png</logo>
+		</feed>`,
+		"json": `{"version": "https://jsonfeed.org/version/1.1", "title": "Blog", "icon": "https://example.com/logo.png", "items": []}`,
+	}
+
+	for format, document := range documents {
+		feed, err := decodeFeed("", []byte(document))
+		if err != nil {
+			t.Fatalf("decodeFeed(%s) returned unexpected error: %v", format, err)
+		}
+		if feed.Channel.Image == nil || feed.Channel.Image.Url != "https://example.com/logo.png" {
+			t.Errorf("%s: expected the logo as channel image, got: %+v", format, feed.Channel.Image)
+		}
+	}
+
+	feed, err := decodeFeed("", []byte(`<rss><channel><title>No image</title></channel></rss>`))
+	if err != nil || feed.Channel.Image != nil {
+		t.Errorf("expected no channel image, got: %+v %v", feed, err)
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// feeds are disabled after this many failed fetches in a row, see the enablefeed command
+	maxConsecutiveFailures = 10
+	maxFailureBackoff      = 24 * time.Hour
+)
+
+/*
+Counters for everything an agg session did, updated from the worker goroutines.
+*/
+type aggStats struct {
+	feedsFetched     atomic.Int64
+	feedsNotModified atomic.Int64
+	feedsFailed      atomic.Int64
+	postsCreated     atomic.Int64
+	postsPruned      atomic.Int64
+}
+
+func (s *aggStats) String() string {
+	return fmt.Sprintf("%d feed(s) fetched (%d not modified), %d failed, %d new post(s), %d pruned",
+		s.feedsFetched.Load(), s.feedsNotModified.Load(), s.feedsFailed.Load(), s.postsCreated.Load(), s.postsPruned.Load())
+}
+
+type scrapeOptions struct {
+	// feeds fetched more recently than this are not stale yet and are left for the next tick
+	staleAfter  time.Duration
+	batchSize   int
+	concurrency int
+}
+
+/*
+feedScraper holds the steps scrapeFeeds drives: claiming a batch, fetching one feed and recording how it went.
+*/
+type feedScraper interface {
+	ClaimFeedsToFetch(ctx context.Context, arg database.ClaimFeedsToFetchParams) ([]database.Feed, error)
+	RecordFeedSuccess(ctx context.Context, id uuid.UUID) error
+	RecordFeedFailure(ctx context.Context, arg database.RecordFeedFailureParams) error
+	scrapeFeed(ctx context.Context, feed database.Feed, stats *aggStats) error
+}
+
+// the feedScraper of agg, backed by the database and the feed client of the state
+type stateScraper struct {
+	*database.Queries
+	state *state
+}
+
+func newStateScraper(state *state) stateScraper {
+	return stateScraper{Queries: state.dbQueriesData, state: state}
+}
+
+func (s stateScraper) scrapeFeed(ctx context.Context, feed database.Feed, stats *aggStats) error {
+	return scrapeFeed(ctx, s.state, feed, stats)
+}
+
+/*
+*
+This method claims a batch of stale feeds and fetches them with at most opts.concurrency goroutines.
+Claiming marks the feeds as fetched inside the same statement (with skip locked), so several agg
+processes can run against the same database without fetching the same feed twice.
+Cancelling ctx aborts in-flight fetches and stops dispatching, but posts of a feed that was already
+downloaded are still written.
+*/
+func scrapeFeeds(ctx context.Context, scraper feedScraper, opts scrapeOptions, stats *aggStats) error {
+	now := time.Now()
+	feeds, err := scraper.ClaimFeedsToFetch(ctx, database.ClaimFeedsToFetchParams{
+		FetchedAt:   now,
+		StaleBefore: now.Add(-opts.staleAfter),
+		BatchSize:   int32(opts.batchSize),
+	})
+	if err != nil {
+		return fmt.Errorf("error when scrape feed when claim feeds %v", err)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    []error
+		workers = make(chan struct{}, opts.concurrency)
+	)
+dispatch:
+	for _, feed := range feeds {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+		// a slot may free up at the same time, never start a feed once cancelled
+		if ctx.Err() != nil {
+			<-workers
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func(feed database.Feed) {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			scrapeErr := scraper.scrapeFeed(ctx, feed, stats)
+			if ctx.Err() != nil && errors.Is(scrapeErr, context.Canceled) {
+				// Shutting down is not the feed's fault.
+				return
+			}
+			if scrapeErr != nil {
+				stats.feedsFailed.Add(1)
+			}
+
+			// A broken feed is recorded on its row and retried later, it must not stop the other feeds.
+			if err := recordScrapeOutcome(context.WithoutCancel(ctx), scraper, feed, opts, scrapeErr); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(feed)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+/*
+*
+This method resets the failure counter on success, otherwise it bumps it, pushes the next fetch back
+exponentially (staleAfter, 2x, 4x, ... capped at maxFailureBackoff) and disables the feed once
+maxConsecutiveFailures is reached. A 410 Gone disables the feed right away and a rate limit only
+delays the next fetch (honoring Retry-After) without counting as a failure. Only database errors are returned.
+*/
+func recordScrapeOutcome(ctx context.Context, scraper feedScraper, feed database.Feed, opts scrapeOptions, scrapeErr error) error {
+	if scrapeErr == nil {
+		if err := scraper.RecordFeedSuccess(ctx, feed.ID); err != nil {
+			return fmt.Errorf("error when scrape feed %s on record success %v", feed.Url, err)
+		}
+		return nil
+	}
+
+	failures := feed.ConsecutiveFailures + 1
+	disabled := failures >= maxConsecutiveFailures
+	nextFetchAt := time.Now().Add(failureBackoff(opts.staleAfter, failures))
+
+	var fetchErr *fetchError
+	switch {
+	case errors.Is(scrapeErr, errFeedGone):
+		// 410 means the publisher removed the feed on purpose, there is no point retrying.
+		disabled = true
+	case errors.Is(scrapeErr, errFeedRateLimited) && errors.As(scrapeErr, &fetchErr):
+		// Being rate limited says nothing about the feed itself, wait as long as we were asked to.
+		failures = feed.ConsecutiveFailures
+		disabled = false
+		if fetchErr.RetryAfter > 0 {
+			nextFetchAt = time.Now().Add(fetchErr.RetryAfter)
+		}
+	}
+
+	err := scraper.RecordFeedFailure(ctx, database.RecordFeedFailureParams{
+		ConsecutiveFailures: failures,
+		LastError:           scrapeErr.Error(),
+		NextFetchAt: sql.NullTime{
+			Time:  nextFetchAt,
+			Valid: true,
+		},
+		Disabled: disabled,
+		ID:       feed.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error when scrape feed %s on record failure %v", feed.Url, err)
+	}
+
+	if errors.Is(scrapeErr, errFeedGone) {
+		fmt.Printf("Feed %s is gone, marked as dead: %v\n", feed.Url, scrapeErr)
+	} else if disabled {
+		fmt.Printf("Feed %s disabled after %d consecutive failures: %v\n", feed.Url, failures, scrapeErr)
+	} else {
+		fmt.Printf("Feed %s failed (%d in a row), retrying after %s: %v\n", feed.Url, failures, nextFetchAt.Format(time.RFC1123), scrapeErr)
+	}
+	return nil
+}
+
+func failureBackoff(base time.Duration, failures int32) time.Duration {
+	if base <= 0 {
+		base = time.Minute
+	}
+
+	backoff := base
+	for i := int32(1); i < failures; i++ {
+		backoff *= 2
+		if backoff >= maxFailureBackoff {
+			return maxFailureBackoff
+		}
+	}
+	return backoff
+}
+
+func scrapeFeed(ctx context.Context, state *state, feed database.Feed, stats *aggStats) error {
+	result, err := state.feedClient.fetchFeed(ctx, feed.Url, feedCache{
+		ETag:         feed.Etag,
+		LastModified: feed.LastModified,
+	})
+	if err != nil {
+		return fmt.Errorf("error when scrape feed %s on fetch feed fetched %w", feed.Url, err)
+	}
+	// The document is downloaded, let the writes below finish even if agg is shutting down.
+	writeCtx := context.WithoutCancel(ctx)
+
+	stats.feedsFetched.Add(1)
+	if result.NotModified {
+		stats.feedsNotModified.Add(1)
+		fmt.Printf("Feed %s not modified since last fetch\n", feed.Url)
+		return followPermanentRedirect(writeCtx, state, feed, result.PermanentUrl)
+	}
+	feeds := result.Feed
+
+	stored, err := storeFeedItems(writeCtx, state.dbQueriesData, state.retention, feed, result)
+	stats.postsCreated.Add(stored.created)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Fetched %d item(s) from %s (%s)\n", len(feeds.Channel.Item), feeds.Channel.Title, feed.Url)
+	stored.printFailures(feed.Url)
+
+	return followPermanentRedirect(writeCtx, state, feed, result.PermanentUrl)
+}
+
+/*
+feedItemStore holds the writes of storeFeedItems, shared by agg, addfeed and the api.
+*/
+type feedItemStore interface {
+	CreatePost(ctx context.Context, arg database.CreatePostParams) (int64, error)
+	UpdateFeedMetadata(ctx context.Context, arg database.UpdateFeedMetadataParams) error
+	UpdateFeedCache(ctx context.Context, arg database.UpdateFeedCacheParams) error
+}
+
+// what storeFeedItems did with the items of a document
+type storedItems struct {
+	created int64
+	// items stored with the fetch time because their published date could not be read
+	dateFailures []string
+	// items the database refused, the other items are stored anyway
+	itemFailures []string
+}
+
+/*
+*
+This method stores the items of a fetched feed as posts (known and pruned urls are skipped, as are items the
+retention would prune right away), then refreshes the channel metadata and the cache validators of the feed
+row. An item the database refuses is recorded and skipped, only a lost connection (or context) stops the rest.
+*/
+func storeFeedItems(ctx context.Context, store feedItemStore, retention retentionPolicy, feed database.Feed, result *fetchResult) (storedItems, error) {
+	fetchedAt := time.Now()
+	var stored storedItems
+
+	items := result.Feed.Channel.Item
+	publishedTimes := make([]time.Time, len(items))
+	for idx, item := range items {
+		publishedTime, err := parsePubDate(item.PubDate, item.DcDate)
+		if err != nil {
+			// A missing or odd date should not cost us the item, use the time we fetched it instead.
+			if !errors.Is(err, errNoPubDate) {
+				stored.dateFailures = append(stored.dateFailures, fmt.Sprintf("%s: %v", item.Title, err))
+			}
+			publishedTime = fetchedAt
+		}
+		publishedTimes[idx] = publishedTime
+	}
+
+	// prune would delete these again, and the next fetch would bring them back
+	withinLimit := retention.withinFeedLimit(publishedTimes)
+	for idx, item := range items {
+		publishedTime := publishedTimes[idx]
+		if !withinLimit[idx] || publishedTime.Before(retention.expiredBefore(fetchedAt)) {
+			continue
+		}
+
+		rows, err := store.CreatePost(ctx, database.CreatePostParams{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+			Title:       item.Title,
+			Url:         item.Link,
+			Description: item.Description,
+			PublishedAt: publishedTime,
+			FeedID:      feed.ID,
+		})
+		if err != nil && isItemError(err) {
+			stored.itemFailures = append(stored.itemFailures, fmt.Sprintf("%s (%s): %v", item.Title, item.Link, err))
+			continue
+		}
+		if err != nil {
+			return stored, fmt.Errorf("error when scrape feed %s on create post index %d: %v", feed.Url, idx, err)
+		}
+		stored.created += rows
+	}
+
+	channel := result.Feed.Channel
+	imageUrl := ""
+	if channel.Image != nil {
+		imageUrl = channel.Image.Url
+	}
+	err := store.UpdateFeedMetadata(ctx, database.UpdateFeedMetadataParams{
+		Link:        channel.Link,
+		Description: channel.Description,
+		ImageUrl:    imageUrl,
+		ID:          feed.ID,
+	})
+	if err != nil {
+		return stored, fmt.Errorf("error when scrape feed %s on update feed metadata %v", feed.Url, err)
+	}
+
+	// Only remember the validators once the items are stored, otherwise a failed write would be skipped as 304 next time.
+	err = store.UpdateFeedCache(ctx, database.UpdateFeedCacheParams{
+		Etag:         result.Cache.ETag,
+		LastModified: result.Cache.LastModified,
+		ID:           feed.ID,
+	})
+	if err != nil {
+		return stored, fmt.Errorf("error when scrape feed %s on update feed cache %v", feed.Url, err)
+	}
+	return stored, nil
+}
+
+/*
+*
+This method tells a row the database refused (bad encoding, a constraint...) from a failure of the database
+itself, after which the other items would fail too.
+*/
+func isItemError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code.Class() {
+	// connection exception, insufficient resources, operator intervention, system error
+	case "08", "53", "57", "58":
+		return false
+	}
+	return true
+}
+
+func (s storedItems) printFailures(feedUrl string) {
+	if len(s.dateFailures) > 0 {
+		fmt.Printf("%d item(s) in %s had an unparseable published date, used fetch time instead:\n", len(s.dateFailures), feedUrl)
+		for _, failure := range s.dateFailures {
+			fmt.Printf("  %s\n", failure)
+		}
+	}
+	if len(s.itemFailures) > 0 {
+		fmt.Printf("%d item(s) in %s could not be stored:\n", len(s.itemFailures), feedUrl)
+		for _, failure := range s.itemFailures {
+			fmt.Printf("  %s\n", failure)
+		}
+	}
+}
+
+/*
+*
+This method points the feed row at the url the publisher permanently moved it to. When another feed
+already uses that url the two are merged: follows and posts move over to the existing feed and this one is deleted.
+*/
+func followPermanentRedirect(ctx context.Context, state *state, feed database.Feed, newUrl string) error {
+	if newUrl == "" || newUrl == feed.Url {
+		return nil
+	}
+
+	tx, err := state.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error when scrape feed %s on begin redirect update %v", feed.Url, err)
+	}
+	defer tx.Rollback()
+	queries := state.dbQueriesData.WithTx(tx)
+
+	existing, err := queries.GetFeedByUrl(ctx, newUrl)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = queries.UpdateFeedUrl(ctx, database.UpdateFeedUrlParams{
+			Url: newUrl,
+			ID:  feed.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("error when scrape feed %s on update url %v", feed.Url, err)
+		}
+		fmt.Printf("Feed %s permanently moved, url updated to %s\n", feed.Url, newUrl)
+	case err != nil:
+		return fmt.Errorf("error when scrape feed %s on get redirected feed %v", feed.Url, err)
+	default:
+		err = queries.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{
+			ToFeedID:   existing.ID,
+			FromFeedID: feed.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("error when scrape feed %s on move follows %v", feed.Url, err)
+		}
+		err = queries.MovePostsToFeed(ctx, database.MovePostsToFeedParams{
+			ToFeedID:   existing.ID,
+			FromFeedID: feed.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("error when scrape feed %s on move posts %v", feed.Url, err)
+		}
+		if err := queries.DeleteFeed(ctx, feed.ID); err != nil {
+			return fmt.Errorf("error when scrape feed %s on delete merged feed %v", feed.Url, err)
+		}
+		fmt.Printf("Feed %s permanently moved to %s, merged into existing feed %s\n", feed.Url, newUrl, existing.Name)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func TestFailureBackoff_Doubles(t *testing.T) {
+	expected := map[int32]time.Duration{
+		1: time.Minute,
+		2: 2 * time.Minute,
+		3: 4 * time.Minute,
+		5: 16 * time.Minute,
+	}
+
+	for failures, want := range expected {
+		if got := failureBackoff(time.Minute, failures); got != want {
+			t.Errorf("failureBackoff(1m, %d) expected %v, got: %v", failures, want, got)
+		}
+	}
+}
+
+func TestFailureBackoff_Capped(t *testing.T) {
+	if got := failureBackoff(time.Hour, 20); got != maxFailureBackoff {
+		t.Errorf("expected backoff to be capped at %v, got: %v", maxFailureBackoff, got)
+	}
+}
+
+func TestIsItemError(t *testing.T) {
+	expected := map[error]bool{
+		&pq.Error{Code: "22021"}:                            true, // invalid byte sequence for encoding
+		&pq.Error{Code: "23505"}:                            true,
+		&pq.Error{Code: "08006"}:                            false,
+		&pq.Error{Code: "57P01"}:                            false,
+		context.Canceled:                                    false,
+		driver.ErrBadConn:                                   false,
+		fmt.Errorf("wrapped: %w", &pq.Error{Code: "23502"}): true,
+	}
+
+	for err, want := range expected {
+		if got := isItemError(err); got != want {
+			t.Errorf("isItemError(%v) expected %v, got: %v", err, want, got)
+		}
+	}
+}
+
+/*
+fakeScraper hands out a fixed batch and records what scrapeFeeds did with it, fetch decides how each feed goes.
+*/
+type fakeScraper struct {
+	feeds []database.Feed
+	fetch func(ctx context.Context, feed database.Feed) error
+
+	mu        sync.Mutex
+	claim     database.ClaimFeedsToFetchParams
+	fetched   []string
+	succeeded []uuid.UUID
+	failed    []database.RecordFeedFailureParams
+	inFlight  int
+	maxFlight int
+}
+
+func (f *fakeScraper) ClaimFeedsToFetch(ctx context.Context, arg database.ClaimFeedsToFetchParams) ([]database.Feed, error) {
+	f.claim = arg
+	return f.feeds[:min(int(arg.BatchSize), len(f.feeds))], nil
+}
+
+func (f *fakeScraper) RecordFeedSuccess(ctx context.Context, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.succeeded = append(f.succeeded, id)
+	return nil
+}
+
+func (f *fakeScraper) RecordFeedFailure(ctx context.Context, arg database.RecordFeedFailureParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failed = append(f.failed, arg)
+	return nil
+}
+
+func (f *fakeScraper) scrapeFeed(ctx context.Context, feed database.Feed, stats *aggStats) error {
+	f.mu.Lock()
+	f.fetched = append(f.fetched, feed.Url)
+	f.inFlight++
+	f.maxFlight = max(f.maxFlight, f.inFlight)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+	return f.fetch(ctx, feed)
+}
+
+func testScrapeFeeds(count int) []database.Feed {
+	feeds := make([]database.Feed, count)
+	for idx := range feeds {
+		feeds[idx] = database.Feed{ID: uuid.New(), Url: fmt.Sprintf("https://example.com/%d.xml", idx)}
+	}
+	return feeds
+}
+
+func TestScrapeFeeds_ClaimAndRecord(t *testing.T) {
+	feeds := testScrapeFeeds(4)
+	feeds[1].ConsecutiveFailures = 2
+	scraper := &fakeScraper{feeds: feeds, fetch: func(ctx context.Context, feed database.Feed) error {
+		if feed.ID == feeds[1].ID {
+			return errors.New("connection reset")
+		}
+		return nil
+	}}
+	stats := &aggStats{}
+
+	err := scrapeFeeds(context.Background(), scraper, scrapeOptions{staleAfter: time.Hour, batchSize: 3, concurrency: 2}, stats)
+	if err != nil {
+		t.Fatalf("scrapeFeeds() returned unexpected error: %v", err)
+	}
+
+	if scraper.claim.BatchSize != 3 || !scraper.claim.StaleBefore.Equal(scraper.claim.FetchedAt.Add(-time.Hour)) {
+		t.Errorf("unexpected claim arguments: %+v", scraper.claim)
+	}
+	if len(scraper.fetched) != 3 || len(scraper.succeeded) != 2 || len(scraper.failed) != 1 {
+		t.Fatalf("expected 3 feeds fetched, 2 successes and 1 failure, got: %v %v %v", scraper.fetched, scraper.succeeded, scraper.failed)
+	}
+	if failure := scraper.failed[0]; failure.ID != feeds[1].ID || failure.ConsecutiveFailures != 3 || failure.Disabled {
+		t.Errorf("expected the failure counted on the feed, got: %+v", failure)
+	}
+	if stats.feedsFailed.Load() != 1 {
+		t.Errorf("expected 1 failed feed in the stats, got: %d", stats.feedsFailed.Load())
+	}
+}
+
+func TestScrapeFeeds_BoundedConcurrency(t *testing.T) {
+	scraper := &fakeScraper{feeds: testScrapeFeeds(8), fetch: func(ctx context.Context, feed database.Feed) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}}
+
+	err := scrapeFeeds(context.Background(), scraper, scrapeOptions{staleAfter: time.Hour, batchSize: 8, concurrency: 3}, &aggStats{})
+	if err != nil {
+		t.Fatalf("scrapeFeeds() returned unexpected error: %v", err)
+	}
+	if len(scraper.succeeded) != 8 {
+		t.Errorf("expected every feed fetched, got: %d", len(scraper.succeeded))
+	}
+	if scraper.maxFlight > 3 {
+		t.Errorf("expected at most 3 fetches at once, got: %d", scraper.maxFlight)
+	}
+}
+
+func TestScrapeFeeds_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scraper := &fakeScraper{feeds: testScrapeFeeds(3), fetch: func(ctx context.Context, feed database.Feed) error {
+		// agg is interrupted while the first feed downloads
+		cancel()
+		return fmt.Errorf("fetch %s: %w", feed.Url, ctx.Err())
+	}}
+	stats := &aggStats{}
+
+	err := scrapeFeeds(ctx, scraper, scrapeOptions{staleAfter: time.Hour, batchSize: 3, concurrency: 1}, stats)
+	if err != nil {
+		t.Fatalf("scrapeFeeds() returned unexpected error: %v", err)
+	}
+	if len(scraper.fetched) != 1 {
+		t.Errorf("expected no feed started after the cancellation, got: %v", scraper.fetched)
+	}
+	if len(scraper.succeeded) != 0 || len(scraper.failed) != 0 || stats.feedsFailed.Load() != 0 {
+		t.Errorf("expected the interrupted fetch not to count as a failure, got: %v %v", scraper.succeeded, scraper.failed)
+	}
+}
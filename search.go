@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+var errEmptySearch = errors.New("search query has no searchable words")
+
+/*
+*
+This method turns what the user typed into a to_tsquery expression:
+
+	go generics      -> go & generics
+	"error handling" -> (error <-> handling)
+	gen*             -> gen:*
+	-java            -> !java
+	-"foo bar"       -> !(foo <-> bar)
+	go OR rust       -> go | rust
+
+Everything but letters and digits is dropped from the words, so user input can never inject tsquery syntax.
+*/
+func buildTsQuery(input string) (string, error) {
+	var (
+		parts       []string
+		operators   []string
+		nextOr      bool
+		inQuote     bool
+		quoteWords  []string
+		negateQuote bool
+	)
+
+	appendPart := func(part string) {
+		if len(parts) > 0 {
+			if nextOr {
+				operators = append(operators, " | ")
+			} else {
+				operators = append(operators, " & ")
+			}
+		}
+		nextOr = false
+		parts = append(parts, part)
+	}
+	appendQuote := func() {
+		if phrase := tsPhrase(quoteWords); phrase != "" {
+			if negateQuote {
+				phrase = "!" + phrase
+			}
+			appendPart(phrase)
+		}
+		quoteWords = nil
+		negateQuote = false
+	}
+
+	for _, token := range strings.Fields(input) {
+		if !inQuote && strings.HasPrefix(token, `-"`) {
+			negateQuote = true
+			token = token[1:]
+		}
+		if inQuote || strings.HasPrefix(token, `"`) {
+			startsQuote := !inQuote
+			inQuote = true
+			endsQuote := strings.HasSuffix(token, `"`) && (!startsQuote || len(token) > 1)
+			quoteWords = append(quoteWords, tsWords(strings.Trim(token, `"`))...)
+			if endsQuote {
+				inQuote = false
+				appendQuote()
+			}
+			continue
+		}
+
+		if token == "OR" {
+			nextOr = len(parts) > 0
+			continue
+		}
+
+		negate := strings.HasPrefix(token, "-")
+		prefix := strings.HasSuffix(token, "*")
+		words := tsWords(strings.TrimSuffix(strings.TrimPrefix(token, "-"), "*"))
+		if len(words) == 0 {
+			continue
+		}
+
+		part := tsPhrase(words)
+		if prefix {
+			part = strings.TrimSuffix(part, ")")
+			part += ":*"
+			if len(words) > 1 {
+				part += ")"
+			}
+		}
+		if negate {
+			part = "!" + part
+		}
+		appendPart(part)
+	}
+
+	// an unterminated quote still counts as a phrase
+	if inQuote {
+		appendQuote()
+	}
+
+	if len(parts) == 0 {
+		return "", errEmptySearch
+	}
+
+	var builder strings.Builder
+	builder.WriteString(parts[0])
+	for idx, operator := range operators {
+		builder.WriteString(operator)
+		builder.WriteString(parts[idx+1])
+	}
+	return builder.String(), nil
+}
+
+func tsWords(value string) []string {
+	return strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func tsPhrase(words []string) string {
+	switch len(words) {
+	case 0:
+		return ""
+	case 1:
+		return words[0]
+	default:
+		return "(" + strings.Join(words, " <-> ") + ")"
+	}
+}
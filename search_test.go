@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuildTsQuery(t *testing.T) {
+	expected := map[string]string{
+		"go generics":               "go & generics",
+		`"error handling" go`:       "(error <-> handling) & go",
+		"gen*":                      "gen:*",
+		"real-time*":                "(real <-> time:*)",
+		"go -java":                  "go & !java",
+		`go -"foo bar"`:             "go & !(foo <-> bar)",
+		`-"java" go`:                "!java & go",
+		"go OR rust":                "go | rust",
+		"go'; drop table posts; --": "go & drop & table & posts",
+		`"unterminated phrase here`: "(unterminated <-> phrase <-> here)",
+		"Go  Generics":              "go & generics",
+	}
+
+	for input, want := range expected {
+		got, err := buildTsQuery(input)
+		if err != nil {
+			t.Errorf("buildTsQuery(%q) returned unexpected error: %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("buildTsQuery(%q) expected '%s', got: '%s'", input, want, got)
+		}
+	}
+}
+
+func TestBuildTsQuery_Empty(t *testing.T) {
+	for _, input := range []string{"", "   ", "--- !!", "OR"} {
+		if _, err := buildTsQuery(input); !errors.Is(err, errEmptySearch) {
+			t.Errorf("buildTsQuery(%q) expected errEmptySearch, got: %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,927 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultServeAddr    = "localhost:8080"
+	defaultAPIPageLimit = 50
+	maxAPIPageLimit     = 500
+	maxAPIBodyBytes     = 1 << 20
+)
+
+/*
+apiStore lists the queries the api runs, *database.Queries implements it and the tests use an in memory fake.
+*/
+type apiStore interface {
+	browseStore
+	CountUsers(ctx context.Context) (int64, error)
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	GetUserByApiKeyHash(ctx context.Context, apiKeyHash sql.NullString) (database.User, error)
+	GetUserById(ctx context.Context, id uuid.UUID) (database.User, error)
+	GetUsers(ctx context.Context) ([]database.User, error)
+	CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error)
+	GetFeeds(ctx context.Context) ([]database.Feed, error)
+	CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error)
+	DeleteFollow(ctx context.Context, arg database.DeleteFollowParams) error
+	GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedFollowsForUserRow, error)
+	GetPostById(ctx context.Context, id uuid.UUID) (database.GetPostByIdRow, error)
+	MarkPostRead(ctx context.Context, arg database.MarkPostReadParams) error
+	MarkPostUnread(ctx context.Context, arg database.MarkPostUnreadParams) error
+	outputStore
+	feedItemStore
+	feedAdminStore
+	GetFeedById(ctx context.Context, id uuid.UUID) (database.Feed, error)
+}
+
+type apiServer struct {
+	store apiStore
+	// runs fn in one transaction, fn gets the queries of that transaction
+	inTx func(ctx context.Context, fn func(store apiStore) error) error
+	// test fetches the feeds added through the api, as addfeed does
+	client    *feedClient
+	retention retentionPolicy
+	now       func() time.Time
+}
+
+/*
+*
+The in memory store of the tests has no transactions, inTx runs fn on it directly until serve sets a real one.
+*/
+func newAPIServer(store apiStore, client *feedClient, retention retentionPolicy) *apiServer {
+	return &apiServer{
+		store:     store,
+		inTx:      func(ctx context.Context, fn func(store apiStore) error) error { return fn(store) },
+		client:    client,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+type apiUser struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	IsAdmin   bool      `json:"is_admin"`
+	CreatedAt time.Time `json:"created_at"`
+	// only returned once, when the user is created
+	APIKey string `json:"api_key,omitempty"`
+}
+
+type apiFeed struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	Url           string     `json:"url"`
+	Link          string     `json:"link,omitempty"`
+	Description   string     `json:"description,omitempty"`
+	ImageUrl      string     `json:"image_url,omitempty"`
+	UserID        uuid.UUID  `json:"user_id"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	Disabled      bool       `json:"disabled"`
+	Archived      bool       `json:"archived"`
+	Status        string     `json:"status"`
+}
+
+type apiFollow struct {
+	FeedID   uuid.UUID `json:"feed_id"`
+	FeedName string    `json:"feed_name"`
+	FeedUrl  string    `json:"feed_url"`
+	Category string    `json:"category"`
+}
+
+type apiPost struct {
+	ID          uuid.UUID `json:"id"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Description string    `json:"description"`
+	PublishedAt time.Time `json:"published_at"`
+	FeedID      uuid.UUID `json:"feed_id"`
+	FeedName    string    `json:"feed_name,omitempty"`
+	IsRead      bool      `json:"is_read"`
+}
+
+/*
+Every list endpoint answers with a page, next_offset (or next_cursor for posts) is only set when more items may follow.
+*/
+type apiList[T any] struct {
+	Items      []T    `json:"items"`
+	NextOffset *int   `json:"next_offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users", s.withUser(s.handleListUsers))
+	mux.HandleFunc("POST /api/users", s.handleCreateUser)
+	mux.HandleFunc("GET /api/users/{id}", s.withUser(s.handleGetUser))
+	mux.HandleFunc("DELETE /api/users/{id}", s.withUser(s.handleDeleteUser))
+	mux.HandleFunc("GET /api/feeds", s.withUser(s.handleListFeeds))
+	mux.HandleFunc("POST /api/feeds", s.withUser(s.handleCreateFeed))
+	mux.HandleFunc("GET /api/feeds/{id}", s.withUser(s.handleGetFeed))
+	mux.HandleFunc("DELETE /api/feeds/{id}", s.withUser(s.handleDeleteFeed))
+	mux.HandleFunc("GET /api/follows", s.withUser(s.handleListFollows))
+	mux.HandleFunc("POST /api/follows", s.withUser(s.handleCreateFollow))
+	mux.HandleFunc("DELETE /api/follows/{feed_id}", s.withUser(s.handleDeleteFollow))
+	mux.HandleFunc("GET /api/posts", s.withUser(s.handleListPosts))
+	mux.HandleFunc("GET /api/posts/{id}", s.withUser(s.handleGetPost))
+	mux.HandleFunc("PUT /api/posts/{id}/read", s.withUser(s.handleMarkRead))
+	mux.HandleFunc("DELETE /api/posts/{id}/read", s.withUser(s.handleMarkUnread))
+	mux.HandleFunc("GET /api/output/{format}", withQueryKey(s.withUser(s.handleOutputFeed)))
+	mux.HandleFunc("GET /api/output/{format}/{category...}", withQueryKey(s.withUser(s.handleOutputFeed)))
+	return mux
+}
+
+/*
+*
+The api counterpart of middlewareLoggedIn, every request but the creation of the first user needs
+an "Authorization: Bearer <api key>" header.
+*/
+func (s *apiServer) withUser(handler func(w http.ResponseWriter, r *http.Request, user database.User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := s.authenticate(w, r)
+		if !ok {
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+/*
+*
+Feed readers rarely let their users set headers, the output feeds also take the key as ?api_key=.
+*/
+func withQueryKey(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if apiKey := r.URL.Query().Get("api_key"); apiKey != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+		handler(w, r)
+	}
+}
+
+func (s *apiServer) authenticate(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	apiKey, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || apiKey == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeAPIError(w, http.StatusUnauthorized, "missing bearer api key")
+		return database.User{}, false
+	}
+
+	user, err := s.store.GetUserByApiKeyHash(r.Context(), hashAPIKey(apiKey))
+	if errors.Is(err, sql.ErrNoRows) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeAPIError(w, http.StatusUnauthorized, errInvalidAPIKey.Error())
+		return database.User{}, false
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func (s *apiServer) handleListUsers(w http.ResponseWriter, r *http.Request, _ database.User) {
+	limit, offset, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, err := s.store.GetUsers(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	items := make([]apiUser, 0, len(users))
+	for _, user := range users {
+		items = append(items, toAPIUser(user))
+	}
+	writeJSON(w, http.StatusOK, paginate(items, limit, offset))
+}
+
+func (s *apiServer) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := decodeJSON(w, r, &body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Name == "" {
+		writeAPIError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	// same rule as register: the very first user administers the instance, it is the only one created anonymously
+	userCount, err := s.store.CountUsers(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if userCount > 0 {
+		creator, ok := s.authenticate(w, r)
+		if !ok {
+			return
+		}
+		if !creator.IsAdmin {
+			writeAPIError(w, http.StatusForbidden, "only admins can create users")
+			return
+		}
+	}
+
+	apiKey, apiKeyHash, err := generateAPIKey()
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	user, err := s.store.CreateUser(r.Context(), database.CreateUserParams{
+		ID:         uuid.New(),
+		CreatedAt:  s.now(),
+		UpdatedAt:  s.now(),
+		Name:       body.Name,
+		IsAdmin:    userCount == 0,
+		ApiKeyHash: apiKeyHash,
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	created := toAPIUser(user)
+	created.APIKey = apiKey
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (s *apiServer) handleGetUser(w http.ResponseWriter, r *http.Request, _ database.User) {
+	id, ok := pathUUID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	user, err := s.store.GetUserById(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIUser(user))
+}
+
+func (s *apiServer) handleDeleteUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, ok := pathUUID(w, r, "id")
+	if !ok {
+		return
+	}
+	if id != user.ID && !user.IsAdmin {
+		writeAPIError(w, http.StatusForbidden, "only admins can delete other users")
+		return
+	}
+
+	if _, err := s.store.GetUserById(r.Context(), id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if err := s.store.DeleteUser(r.Context(), id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *apiServer) handleListFeeds(w http.ResponseWriter, r *http.Request, _ database.User) {
+	limit, offset, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	feeds, err := s.store.GetFeeds(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	items := make([]apiFeed, 0, len(feeds))
+	for _, feed := range feeds {
+		items = append(items, toAPIFeed(feed))
+	}
+	writeJSON(w, http.StatusOK, paginate(items, limit, offset))
+}
+
+/*
+*
+This method adds a feed the way addfeed does: the url is test fetched (a website is searched for its feeds and
+the first one is used), the name defaults to the feed title and the current items are imported.
+*/
+func (s *apiServer) handleCreateFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	var body struct {
+		Name     string `json:"name"`
+		Url      string `json:"url"`
+		Category string `json:"category"`
+	}
+	if err := decodeJSON(w, r, &body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !isHTTPUrl(body.Url) {
+		writeAPIError(w, http.StatusBadRequest, "an http(s) url is required")
+		return
+	}
+
+	candidates, err := s.client.discoverFeeds(r.Context(), body.Url)
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if len(candidates) == 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("no rss, atom or json feed found at %s", body.Url))
+		return
+	}
+	candidate := candidates[0]
+	name := strings.TrimSpace(cmp.Or(body.Name, candidate.title()))
+	if name == "" {
+		writeAPIError(w, http.StatusBadRequest, "the feed has no title, a name is required")
+		return
+	}
+
+	// the feed, its follow and its posts are created together, a failure or a disconnect leaves none of them
+	var feed database.Feed
+	var stored storedItems
+	err = s.inTx(r.Context(), func(store apiStore) error {
+		feed, err = store.CreateFeed(r.Context(), database.CreateFeedParams{
+			ID:            uuid.New(),
+			Name:          name,
+			Url:           candidate.Url,
+			LastFetchedAt: sql.NullTime{Time: s.now(), Valid: true},
+			UserID:        user.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = store.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: s.now(),
+			UpdatedAt: s.now(),
+			FeedID:    feed.ID,
+			UserID:    user.ID,
+			Category:  body.Category,
+		})
+		if err != nil {
+			return err
+		}
+
+		stored, err = storeFeedItems(r.Context(), store, s.retention, feed, candidate.Result)
+		if err != nil {
+			return err
+		}
+		// read it back with the channel metadata stored above
+		feed, err = store.GetFeedByUrl(r.Context(), feed.Url)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if len(stored.itemFailures) > 0 {
+		log.Printf("%d item(s) of %s could not be stored: %v", len(stored.itemFailures), feed.Url, stored.itemFailures)
+	}
+	writeJSON(w, http.StatusCreated, toAPIFeed(feed))
+}
+
+func (s *apiServer) handleGetFeed(w http.ResponseWriter, r *http.Request, _ database.User) {
+	id, ok := pathUUID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	feed, err := s.store.GetFeedById(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIFeed(feed))
+}
+
+/*
+*
+This method removes a feed the way removefeed does: ?posts= picks the policy (delete by default, move with
+?to=<feed url>, or archive) and only the user who added the feed may remove it.
+*/
+func (s *apiServer) handleDeleteFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, ok := pathUUID(w, r, "id")
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	policy := cmp.Or(query.Get("posts"), deletePostsPolicy)
+	if !slices.Contains(removeFeedPolicies, policy) {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("posts must be one of %s", strings.Join(removeFeedPolicies, ", ")))
+		return
+	}
+	targetUrl := query.Get("to")
+	if (targetUrl != "") != (policy == movePostsPolicy) {
+		writeAPIError(w, http.StatusBadRequest, "to is required by posts=move and only used by it")
+		return
+	}
+
+	feed, err := s.store.GetFeedById(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if _, err := ownedFeed(r.Context(), s.store, user, feed.Url); err != nil {
+		if errors.Is(err, errFeedNotOwned) {
+			writeAPIError(w, http.StatusForbidden, err.Error())
+		} else {
+			writeStoreError(w, err)
+		}
+		return
+	}
+
+	var target database.Feed
+	if policy == movePostsPolicy {
+		target, err = s.store.GetFeedByUrl(r.Context(), targetUrl)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		if target.ID == feed.ID {
+			writeAPIError(w, http.StatusBadRequest, "cannot move the posts of a feed to itself")
+			return
+		}
+	}
+
+	err = s.inTx(r.Context(), func(store apiStore) error {
+		_, err := removeFeed(r.Context(), store, feed, policy, target)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *apiServer) handleListFollows(w http.ResponseWriter, r *http.Request, user database.User) {
+	limit, offset, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	feedFollows, err := s.store.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	items := make([]apiFollow, 0, len(feedFollows))
+	for _, feedFollow := range feedFollows {
+		items = append(items, apiFollow{
+			FeedID:   feedFollow.FeedID,
+			FeedName: feedFollow.FeedName,
+			FeedUrl:  feedFollow.FeedUrl,
+			Category: feedFollow.Category,
+		})
+	}
+	writeJSON(w, http.StatusOK, paginate(items, limit, offset))
+}
+
+func (s *apiServer) handleCreateFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	var body struct {
+		FeedUrl  string `json:"feed_url"`
+		Category string `json:"category"`
+	}
+	if err := decodeJSON(w, r, &body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.FeedUrl == "" {
+		writeAPIError(w, http.StatusBadRequest, "feed_url is required")
+		return
+	}
+
+	feed, err := s.store.GetFeedByUrl(r.Context(), body.FeedUrl)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if feed.Archived {
+		writeAPIError(w, http.StatusConflict, "feed is archived")
+		return
+	}
+
+	feedFollow, err := s.store.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: s.now(),
+		UpdatedAt: s.now(),
+		FeedID:    feed.ID,
+		UserID:    user.ID,
+		Category:  body.Category,
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, apiFollow{
+		FeedID:   feedFollow.FeedID,
+		FeedName: feedFollow.FeedName,
+		FeedUrl:  feed.Url,
+		Category: feedFollow.Category,
+	})
+}
+
+func (s *apiServer) handleDeleteFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, ok := pathUUID(w, r, "feed_id")
+	if !ok {
+		return
+	}
+
+	err := s.store.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		UserID: user.ID,
+		FeedID: feedID,
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+*
+Posts take the same options as the browse command as query parameters (limit, page, cursor, feed, since, until,
+sort, include-read, all), only the default limit differs. Unlike the other lists they do not page with offset:
+new posts keep arriving at the top, so next_cursor (or page) is what walks through them.
+*/
+func (s *apiServer) handleListPosts(w http.ResponseWriter, r *http.Request, user database.User) {
+	args := []string{fmt.Sprintf("--limit=%d", defaultAPIPageLimit)}
+	for name, values := range r.URL.Query() {
+		// checked here, parseCommandArgs would take help for a request of the usage text
+		if err := checkPostsParam(name); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, value := range values {
+			args = append(args, "--"+name+"="+value)
+		}
+	}
+
+	cmd, err := parseCommandArgs(commandSpec{name: "posts", flags: browseFlags}, args)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts, err := browseOptionsFromCommand(cmd, s.now())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if opts.limit > maxAPIPageLimit {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("limit cannot exceed %d", maxAPIPageLimit))
+		return
+	}
+	if opts.allFeeds && !user.IsAdmin {
+		writeAPIError(w, http.StatusForbidden, "only admins can list every feed with all")
+		return
+	}
+
+	posts, err := browsePosts(r.Context(), s.store, user, opts)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	list := apiList[apiPost]{Items: make([]apiPost, 0, len(posts))}
+	for _, post := range posts {
+		list.Items = append(list.Items, apiPost{
+			ID:          post.ID,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			FeedID:      post.FeedID,
+			FeedName:    post.FeedName,
+			IsRead:      post.IsRead,
+		})
+	}
+	if len(posts) == opts.limit {
+		last := posts[len(posts)-1]
+		list.NextCursor = browseCursor{PublishedAt: last.PublishedAt, ID: last.ID}.encode()
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func checkPostsParam(name string) error {
+	names := make([]string, 0, len(browseFlags))
+	for _, flag := range browseFlags {
+		if flag.name == name {
+			return nil
+		}
+		names = append(names, flag.name)
+	}
+	if name == "offset" {
+		return errors.New("posts page with cursor or page, not offset")
+	}
+	return fmt.Errorf("unknown query parameter %s, posts take %s", name, strings.Join(names, ", "))
+}
+
+func (s *apiServer) handleGetPost(w http.ResponseWriter, r *http.Request, _ database.User) {
+	id, ok := pathUUID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	post, err := s.store.GetPostById(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiPost{
+		ID:          post.ID,
+		Title:       post.Title,
+		Url:         post.Url,
+		Description: post.Description,
+		PublishedAt: post.PublishedAt,
+		FeedID:      post.FeedID,
+	})
+}
+
+func (s *apiServer) handleMarkRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	post, ok := s.pathPost(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.store.MarkPostRead(r.Context(), database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+		ReadAt: s.now(),
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *apiServer) handleMarkUnread(w http.ResponseWriter, r *http.Request, user database.User) {
+	post, ok := s.pathPost(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.store.MarkPostUnread(r.Context(), database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+*
+This method serves the posts of the feeds the user follows as RSS 2.0 or Atom, /api/output/{format}/{category}
+narrows them to a category and its sub folders. The self link leaves the api key out.
+*/
+func (s *apiServer) handleOutputFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	format := r.PathValue("format")
+	if _, err := checkValue(stringValue, outputFormats, format); err != nil {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("unknown output format: %v", err))
+		return
+	}
+	category := strings.Trim(r.PathValue("category"), categorySeparator)
+
+	limit := defaultOutputLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxOutputLimit {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxOutputLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	baseUrl := scheme + "://" + r.Host
+
+	feed, err := buildOutputFeed(r.Context(), s.store, user, category, limit, outputSiteUrl(baseUrl), outputFeedUrl(baseUrl, format, category))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", outputContentType(format))
+	if err := writeOutputFeed(w, format, feed, user.Name, s.now()); err != nil {
+		log.Printf("cannot write output feed: %v", err)
+	}
+}
+
+func (s *apiServer) pathPost(w http.ResponseWriter, r *http.Request) (database.GetPostByIdRow, bool) {
+	id, ok := pathUUID(w, r, "id")
+	if !ok {
+		return database.GetPostByIdRow{}, false
+	}
+
+	post, err := s.store.GetPostById(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return database.GetPostByIdRow{}, false
+	}
+	return post, true
+}
+
+func toAPIUser(user database.User) apiUser {
+	return apiUser{
+		ID:        user.ID,
+		Name:      user.Name,
+		IsAdmin:   user.IsAdmin,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func toAPIFeed(feed database.Feed) apiFeed {
+	converted := apiFeed{
+		ID:          feed.ID,
+		Name:        feed.Name,
+		Url:         feed.Url,
+		Link:        feed.Link,
+		Description: feed.Description,
+		ImageUrl:    feed.ImageUrl,
+		UserID:      feed.UserID,
+		Disabled:    feed.Disabled,
+		Archived:    feed.Archived,
+		Status:      feedHealth(feed),
+	}
+	if feed.LastFetchedAt.Valid {
+		converted.LastFetchedAt = &feed.LastFetchedAt.Time
+	}
+	return converted
+}
+
+func parsePageParams(query url.Values) (int, int, error) {
+	limit, offset := defaultAPIPageLimit, 0
+
+	var err error
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxAPIPageLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxAPIPageLimit)
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a positive number")
+		}
+	}
+	return limit, offset, nil
+}
+
+func paginate[T any](items []T, limit, offset int) apiList[T] {
+	list := apiList[T]{Items: []T{}}
+	if offset >= len(items) {
+		return list
+	}
+
+	end := min(offset+limit, len(items))
+	list.Items = items[offset:end]
+	if end < len(items) {
+		list.NextOffset = &end
+	}
+	return list
+}
+
+func pathUUID(w http.ResponseWriter, r *http.Request, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue(name))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s: %v", name, err))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func isHTTPUrl(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, target any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("invalid json body: %v", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("cannot write api response: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{Error: message})
+}
+
+/*
+*
+This method maps database errors onto http statuses, unexpected ones are logged and hidden from the client.
+*/
+func writeStoreError(w http.ResponseWriter, err error) {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeAPIError(w, http.StatusNotFound, "not found")
+	case errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation":
+		writeAPIError(w, http.StatusConflict, "already exists")
+	case errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation":
+		writeAPIError(w, http.StatusConflict, "still referenced by other rows")
+	default:
+		log.Printf("api store error: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+/*
+*
+This method builds the api of serve, its writes spanning several queries run in a transaction of state.db.
+*/
+func newStateAPIServer(state *state) *apiServer {
+	api := newAPIServer(state.dbQueriesData, state.feedClient, state.retention)
+	api.inTx = func(ctx context.Context, fn func(store apiStore) error) error {
+		tx, err := state.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(state.dbQueriesData.WithTx(tx)); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	return api
+}
+
+func handlerServe(state *state, cmd command) error {
+	server := &http.Server{
+		Addr:              cmd.value("--addr"),
+		Handler:           newStateAPIServer(state).routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// same shutdown as agg: Ctrl+C or SIGTERM stop accepting requests and let the running ones finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	fmt.Printf("Serving the api on http://%s/api\n", server.Addr)
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("error on handler serve: %v", err)
+	case <-ctx.Done():
+	}
+
+	fmt.Println("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,649 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var errFakeUniqueViolation = &pq.Error{Code: "23505"}
+
+// fakeStore keeps the rows of the api tests in memory, it only implements what the handlers need
+type fakeStore struct {
+	users   []database.User
+	feeds   []database.Feed
+	follows []database.FeedFollow
+	posts   []database.GetPostByIdRow
+	reads   map[[2]uuid.UUID]bool
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{reads: make(map[[2]uuid.UUID]bool)}
+}
+
+func (f *fakeStore) CountUsers(ctx context.Context) (int64, error) {
+	return int64(len(f.users)), nil
+}
+
+func (f *fakeStore) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	if _, err := f.GetUser(ctx, arg.Name); err == nil {
+		return database.User{}, errFakeUniqueViolation
+	}
+	user := database.User{
+		ID: arg.ID, CreatedAt: arg.CreatedAt, UpdatedAt: arg.UpdatedAt, Name: arg.Name, IsAdmin: arg.IsAdmin, ApiKeyHash: arg.ApiKeyHash,
+	}
+	f.users = append(f.users, user)
+	return user, nil
+}
+
+func (f *fakeStore) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	for idx, user := range f.users {
+		if user.ID == id {
+			f.users = append(f.users[:idx], f.users[idx+1:]...)
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) GetUser(ctx context.Context, name string) (database.User, error) {
+	for _, user := range f.users {
+		if user.Name == name {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) GetUserByApiKeyHash(ctx context.Context, apiKeyHash sql.NullString) (database.User, error) {
+	for _, user := range f.users {
+		if user.ApiKeyHash == apiKeyHash {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) GetUserById(ctx context.Context, id uuid.UUID) (database.User, error) {
+	for _, user := range f.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+// adds a user and returns its api key
+func (f *fakeStore) addUser(t *testing.T, name string, isAdmin bool) (database.User, string) {
+	t.Helper()
+	apiKey, apiKeyHash, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("generateAPIKey() returned unexpected error: %v", err)
+	}
+	user := database.User{ID: uuid.New(), Name: name, IsAdmin: isAdmin, ApiKeyHash: apiKeyHash}
+	f.users = append(f.users, user)
+	return user, apiKey
+}
+
+func (f *fakeStore) GetUsers(ctx context.Context) ([]database.User, error) {
+	return f.users, nil
+}
+
+func (f *fakeStore) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	if _, err := f.GetFeedByUrl(ctx, arg.Url); err == nil {
+		return database.Feed{}, errFakeUniqueViolation
+	}
+	feed := database.Feed{ID: arg.ID, Name: arg.Name, Url: arg.Url, LastFetchedAt: arg.LastFetchedAt, UserID: arg.UserID}
+	f.feeds = append(f.feeds, feed)
+	return feed, nil
+}
+
+func (f *fakeStore) GetFeedByUrl(ctx context.Context, url string) (database.Feed, error) {
+	for _, feed := range f.feeds {
+		if feed.Url == url {
+			return feed, nil
+		}
+	}
+	return database.Feed{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) GetFeedById(ctx context.Context, id uuid.UUID) (database.Feed, error) {
+	for _, feed := range f.feeds {
+		if feed.ID == id {
+			return feed, nil
+		}
+	}
+	return database.Feed{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) GetFeeds(ctx context.Context) ([]database.Feed, error) {
+	return f.feeds, nil
+}
+
+func (f *fakeStore) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	f.feeds = slices.DeleteFunc(f.feeds, func(feed database.Feed) bool { return feed.ID == id })
+	return f.DeleteFeedFollows(ctx, id)
+}
+
+func (f *fakeStore) DeleteFeedFollows(ctx context.Context, feedID uuid.UUID) error {
+	f.follows = slices.DeleteFunc(f.follows, func(follow database.FeedFollow) bool { return follow.FeedID == feedID })
+	return nil
+}
+
+func (f *fakeStore) ArchiveFeed(ctx context.Context, id uuid.UUID) error {
+	for idx := range f.feeds {
+		if f.feeds[idx].ID == id {
+			f.feeds[idx].Archived, f.feeds[idx].Disabled = true, true
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) MovePostsToFeed(ctx context.Context, arg database.MovePostsToFeedParams) error {
+	for idx := range f.posts {
+		if f.posts[idx].FeedID == arg.FromFeedID {
+			f.posts[idx].FeedID = arg.ToFeedID
+		}
+	}
+	return nil
+}
+
+// the fake has no saved posts, every post of the feed goes
+func (f *fakeStore) DeletePostsOfFeed(ctx context.Context, feedID uuid.UUID) (int64, error) {
+	before := len(f.posts)
+	f.posts = slices.DeleteFunc(f.posts, func(post database.GetPostByIdRow) bool { return post.FeedID == feedID })
+	return int64(before - len(f.posts)), nil
+}
+
+func (f *fakeStore) CountPostsOfFeed(ctx context.Context, feedID uuid.UUID) (int64, error) {
+	var count int64
+	for _, post := range f.posts {
+		if post.FeedID == feedID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeStore) CreatePost(ctx context.Context, arg database.CreatePostParams) (int64, error) {
+	for _, post := range f.posts {
+		if post.Url == arg.Url {
+			return 0, nil
+		}
+	}
+	f.posts = append(f.posts, database.GetPostByIdRow{
+		ID: arg.ID, Title: arg.Title, Url: arg.Url, Description: arg.Description, PublishedAt: arg.PublishedAt, FeedID: arg.FeedID,
+	})
+	return 1, nil
+}
+
+func (f *fakeStore) UpdateFeedMetadata(ctx context.Context, arg database.UpdateFeedMetadataParams) error {
+	for idx := range f.feeds {
+		if f.feeds[idx].ID == arg.ID {
+			f.feeds[idx].Link, f.feeds[idx].Description, f.feeds[idx].ImageUrl = arg.Link, arg.Description, arg.ImageUrl
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) UpdateFeedCache(ctx context.Context, arg database.UpdateFeedCacheParams) error {
+	return nil
+}
+
+func (f *fakeStore) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	if f.isFollowing(arg.UserID, arg.FeedID) {
+		return database.CreateFeedFollowRow{}, errFakeUniqueViolation
+	}
+	f.follows = append(f.follows, database.FeedFollow{ID: arg.ID, FeedID: arg.FeedID, UserID: arg.UserID, Category: arg.Category})
+	return database.CreateFeedFollowRow{ID: arg.ID, FeedID: arg.FeedID, UserID: arg.UserID, Category: arg.Category}, nil
+}
+
+func (f *fakeStore) DeleteFollow(ctx context.Context, arg database.DeleteFollowParams) error {
+	for idx, follow := range f.follows {
+		if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+			f.follows = append(f.follows[:idx], f.follows[idx+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedFollowsForUserRow, error) {
+	var rows []database.GetFeedFollowsForUserRow
+	for _, follow := range f.follows {
+		if follow.UserID != userID {
+			continue
+		}
+		for _, feed := range f.feeds {
+			if feed.ID == follow.FeedID {
+				rows = append(rows, database.GetFeedFollowsForUserRow{
+					FeedID: feed.ID, UserID: userID, Category: follow.Category, FeedName: feed.Name, FeedUrl: feed.Url,
+				})
+			}
+		}
+	}
+	return rows, nil
+}
+
+func (f *fakeStore) isFollowing(userID, feedID uuid.UUID) bool {
+	for _, follow := range f.follows {
+		if follow.UserID == userID && follow.FeedID == feedID {
+			return true
+		}
+	}
+	return false
+}
+
+// newest first only, the cursor and the time filters are covered by the sql query itself
+func (f *fakeStore) BrowsePostsNewestFirst(ctx context.Context, arg database.BrowsePostsNewestFirstParams) ([]database.BrowsePostsNewestFirstRow, error) {
+	var rows []database.BrowsePostsNewestFirstRow
+	for _, post := range f.posts {
+		isRead := f.reads[[2]uuid.UUID{arg.UserID, post.ID}]
+		if !arg.AllFeeds && !f.isFollowing(arg.UserID, post.FeedID) ||
+			!arg.IncludeRead && isRead ||
+			arg.FeedID.Valid && post.FeedID != arg.FeedID.UUID {
+			continue
+		}
+		rows = append(rows, database.BrowsePostsNewestFirstRow{
+			ID: post.ID, Title: post.Title, Url: post.Url, PublishedAt: post.PublishedAt, FeedID: post.FeedID, IsRead: isRead,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].PublishedAt.After(rows[j].PublishedAt) })
+
+	start := min(int(arg.PostOffset), len(rows))
+	end := min(start+int(arg.PostLimit), len(rows))
+	return rows[start:end], nil
+}
+
+func (f *fakeStore) BrowsePostsOldestFirst(ctx context.Context, arg database.BrowsePostsOldestFirstParams) ([]database.BrowsePostsOldestFirstRow, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) GetFollowedPosts(ctx context.Context, arg database.GetFollowedPostsParams) ([]database.GetFollowedPostsRow, error) {
+	var rows []database.GetFollowedPostsRow
+	for _, follow := range f.follows {
+		category := arg.Category.String
+		if follow.UserID != arg.UserID ||
+			arg.Category.Valid && follow.Category != category && !strings.HasPrefix(follow.Category, category+"/") {
+			continue
+		}
+		var feed database.Feed
+		for _, candidate := range f.feeds {
+			if candidate.ID == follow.FeedID {
+				feed = candidate
+			}
+		}
+		for _, post := range f.posts {
+			if post.FeedID == follow.FeedID {
+				rows = append(rows, database.GetFollowedPostsRow{
+					ID: post.ID, Title: post.Title, Url: post.Url, Description: post.Description, PublishedAt: post.PublishedAt,
+					FeedID: post.FeedID, FeedName: feed.Name, FeedUrl: feed.Url, Category: follow.Category,
+				})
+			}
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].PublishedAt.After(rows[j].PublishedAt) })
+	return rows[:min(int(arg.PostLimit), len(rows))], nil
+}
+
+func (f *fakeStore) GetPostById(ctx context.Context, id uuid.UUID) (database.GetPostByIdRow, error) {
+	for _, post := range f.posts {
+		if post.ID == id {
+			return post, nil
+		}
+	}
+	return database.GetPostByIdRow{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) MarkPostRead(ctx context.Context, arg database.MarkPostReadParams) error {
+	f.reads[[2]uuid.UUID{arg.UserID, arg.PostID}] = true
+	return nil
+}
+
+func (f *fakeStore) MarkPostUnread(ctx context.Context, arg database.MarkPostUnreadParams) error {
+	delete(f.reads, [2]uuid.UUID{arg.UserID, arg.PostID})
+	return nil
+}
+
+func testAPIRequest(t *testing.T, handler http.Handler, method, target, apiKey, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func decodeTestResponse[T any](t *testing.T, recorder *httptest.ResponseRecorder) T {
+	t.Helper()
+	var value T
+	if err := json.NewDecoder(recorder.Body).Decode(&value); err != nil {
+		t.Fatalf("cannot decode response %q: %v", recorder.Body.String(), err)
+	}
+	return value
+}
+
+func TestAPI_CreateUsers(t *testing.T) {
+	handler := newAPIServer(newFakeStore(), testFeedClient(t), retentionPolicy{}).routes()
+
+	first := testAPIRequest(t, handler, http.MethodPost, "/api/users", "", `{"name":"alice"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got: %d %s", first.Code, first.Body)
+	}
+	alice := decodeTestResponse[apiUser](t, first)
+	if !alice.IsAdmin || alice.Name != "alice" || !strings.HasPrefix(alice.APIKey, apiKeyPrefix) {
+		t.Errorf("expected first user alice to be admin with an api key, got: %+v", alice)
+	}
+
+	anonymous := testAPIRequest(t, handler, http.MethodPost, "/api/users", "", `{"name":"bob"}`)
+	if anonymous.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 for a second anonymous user, got: %d", anonymous.Code)
+	}
+
+	second := testAPIRequest(t, handler, http.MethodPost, "/api/users", alice.APIKey, `{"name":"bob"}`)
+	bob := decodeTestResponse[apiUser](t, second)
+	if bob.IsAdmin || bob.APIKey == "" || bob.APIKey == alice.APIKey {
+		t.Errorf("expected second user not to be admin with its own key, got: %+v", bob)
+	}
+
+	byUser := testAPIRequest(t, handler, http.MethodPost, "/api/users", bob.APIKey, `{"name":"carol"}`)
+	if byUser.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 when a non admin creates a user, got: %d", byUser.Code)
+	}
+
+	duplicate := testAPIRequest(t, handler, http.MethodPost, "/api/users", alice.APIKey, `{"name":"bob"}`)
+	if duplicate.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a duplicated user, got: %d", duplicate.Code)
+	}
+
+	invalid := testAPIRequest(t, handler, http.MethodPost, "/api/users", alice.APIKey, `{"nickname":"carol"}`)
+	if invalid.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown field, got: %d", invalid.Code)
+	}
+}
+
+func TestAPI_ListUsersPagination(t *testing.T) {
+	store := newFakeStore()
+	_, apiKey := store.addUser(t, "alice", true)
+	store.addUser(t, "bob", false)
+	store.addUser(t, "carol", false)
+	handler := newAPIServer(store, testFeedClient(t), retentionPolicy{}).routes()
+
+	page := decodeTestResponse[apiList[apiUser]](t, testAPIRequest(t, handler, http.MethodGet, "/api/users?limit=2", apiKey, ""))
+	if len(page.Items) != 2 || page.NextOffset == nil || *page.NextOffset != 2 {
+		t.Fatalf("expected 2 users and next offset 2, got: %+v", page)
+	}
+
+	last := decodeTestResponse[apiList[apiUser]](t, testAPIRequest(t, handler, http.MethodGet, "/api/users?limit=2&offset=2", apiKey, ""))
+	if len(last.Items) != 1 || last.Items[0].Name != "carol" || last.NextOffset != nil {
+		t.Errorf("expected carol on the last page, got: %+v", last)
+	}
+
+	if code := testAPIRequest(t, handler, http.MethodGet, "/api/users?limit=0", apiKey, "").Code; code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for limit 0, got: %d", code)
+	}
+}
+
+func TestAPI_RequiresAPIKey(t *testing.T) {
+	store := newFakeStore()
+	store.addUser(t, "alice", true)
+	handler := newAPIServer(store, testFeedClient(t), retentionPolicy{}).routes()
+
+	for _, target := range []string{"/api/users", "/api/feeds", "/api/follows", "/api/posts"} {
+		recorder := testAPIRequest(t, handler, http.MethodGet, target, "", "")
+		if recorder.Code != http.StatusUnauthorized || recorder.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("GET %s without key expected status 401 with a challenge, got: %d", target, recorder.Code)
+		}
+	}
+	if code := testAPIRequest(t, handler, http.MethodGet, "/api/follows", apiKeyPrefix+"forged", "").Code; code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 for an unknown key, got: %d", code)
+	}
+}
+
+func TestAPI_FeedsAndFollows(t *testing.T) {
+	store := newFakeStore()
+	alice, apiKey := store.addUser(t, "alice", false)
+	handler := newAPIServer(store, testFeedClient(t), retentionPolicy{}).routes()
+	site := testFeedSite(t, map[string]string{
+		"/": `<html><head><link rel="alternate" type="application/rss+xml" href="/feed.xml"></head></html>`,
+		"/feed.xml": `<rss version="2.0"><channel><title>Go blog</title><link>https://go.dev/blog</link>
+			<item><title>Go 1.24</title><link>https://go.dev/blog/go1.24</link><pubDate>Tue, 11 Feb 2025 09:00:00 +0000</pubDate></item>
+		</channel></rss>`,
+	})
+	feedUrl := site.URL + "/feed.xml"
+
+	created := testAPIRequest(t, handler, http.MethodPost, "/api/feeds", apiKey, `{"url":"`+site.URL+`/","category":"lang"}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got: %d %s", created.Code, created.Body)
+	}
+	feed := decodeTestResponse[apiFeed](t, created)
+	if feed.Url != feedUrl || feed.Name != "Go blog" || feed.Link != "https://go.dev/blog" {
+		t.Errorf("expected the discovered feed named after its title, got: %+v", feed)
+	}
+	if !strings.HasPrefix(feed.Status, "ok") || feed.UserID != alice.ID {
+		t.Errorf("unexpected feed: %+v", feed)
+	}
+	if len(store.posts) != 1 || store.posts[0].FeedID != feed.ID {
+		t.Errorf("expected the current item to be imported, got: %+v", store.posts)
+	}
+
+	invalid := testAPIRequest(t, handler, http.MethodPost, "/api/feeds", apiKey, `{"name":"x","url":"ftp://example.com"}`)
+	if invalid.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a non http url, got: %d", invalid.Code)
+	}
+	plainSite := testFeedSite(t, map[string]string{"/": `<html><head></head></html>`})
+	notFeed := testAPIRequest(t, handler, http.MethodPost, "/api/feeds", apiKey, `{"name":"x","url":"`+plainSite.URL+`/"}`)
+	if notFeed.Code != http.StatusUnprocessableEntity || len(store.feeds) != 1 {
+		t.Errorf("expected status 422 and no feed for a page without feeds, got: %d", notFeed.Code)
+	}
+
+	follows := decodeTestResponse[apiList[apiFollow]](t, testAPIRequest(t, handler, http.MethodGet, "/api/follows", apiKey, ""))
+	if len(follows.Items) != 1 || follows.Items[0].Category != "lang" {
+		t.Fatalf("expected the created feed to be followed in lang, got: %+v", follows)
+	}
+
+	again := testAPIRequest(t, handler, http.MethodPost, "/api/follows", apiKey, `{"feed_url":"`+feedUrl+`"}`)
+	if again.Code != http.StatusConflict {
+		t.Errorf("expected status 409 when following twice, got: %d", again.Code)
+	}
+	missing := testAPIRequest(t, handler, http.MethodPost, "/api/follows", apiKey, `{"feed_url":"https://example.com/none"}`)
+	if missing.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown feed, got: %d", missing.Code)
+	}
+
+	deleted := testAPIRequest(t, handler, http.MethodDelete, "/api/follows/"+feed.ID.String(), apiKey, "")
+	if deleted.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got: %d", deleted.Code)
+	}
+	follows = decodeTestResponse[apiList[apiFollow]](t, testAPIRequest(t, handler, http.MethodGet, "/api/follows", apiKey, ""))
+	if len(follows.Items) != 0 {
+		t.Errorf("expected no follow left, got: %+v", follows)
+	}
+
+	store.feeds[0].Archived = true
+	archived := testAPIRequest(t, handler, http.MethodPost, "/api/follows", apiKey, `{"feed_url":"`+feedUrl+`"}`)
+	if archived.Code != http.StatusConflict {
+		t.Errorf("expected status 409 when following an archived feed, got: %d", archived.Code)
+	}
+}
+
+func TestAPI_Posts(t *testing.T) {
+	store := newFakeStore()
+	user, apiKey := store.addUser(t, "alice", false)
+	feedID := uuid.New()
+	store.feeds = append(store.feeds, database.Feed{ID: feedID, Name: "Go blog", Url: "https://go.dev/blog/feed.atom"})
+	store.follows = append(store.follows, database.FeedFollow{FeedID: feedID, UserID: user.ID})
+	now := time.Now()
+	for idx := range 3 {
+		store.posts = append(store.posts, database.GetPostByIdRow{
+			ID: uuid.New(), Title: "post", FeedID: feedID, PublishedAt: now.Add(-time.Duration(idx) * time.Hour),
+		})
+	}
+	handler := newAPIServer(store, testFeedClient(t), retentionPolicy{}).routes()
+
+	page := decodeTestResponse[apiList[apiPost]](t, testAPIRequest(t, handler, http.MethodGet, "/api/posts?limit=2", apiKey, ""))
+	if len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("expected a full page with a cursor, got: %+v", page)
+	}
+
+	read := testAPIRequest(t, handler, http.MethodPut, "/api/posts/"+page.Items[0].ID.String()+"/read", apiKey, "")
+	if read.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got: %d", read.Code)
+	}
+	unread := decodeTestResponse[apiList[apiPost]](t, testAPIRequest(t, handler, http.MethodGet, "/api/posts", apiKey, ""))
+	if len(unread.Items) != 2 || unread.NextCursor != "" {
+		t.Errorf("expected the 2 unread posts without cursor, got: %+v", unread)
+	}
+
+	expected := map[string]int{
+		"/api/posts?sort=sideways":            http.StatusBadRequest,
+		"/api/posts?unknown=1":                http.StatusBadRequest,
+		"/api/posts?limit=100000":             http.StatusBadRequest,
+		"/api/posts?all=true":                 http.StatusForbidden,
+		"/api/posts/" + uuid.NewString():      http.StatusNotFound,
+		"/api/posts/not-an-id":                http.StatusBadRequest,
+		"/api/posts?include-read=true&page=2": http.StatusOK,
+	}
+	for target, want := range expected {
+		if got := testAPIRequest(t, handler, http.MethodGet, target, apiKey, "").Code; got != want {
+			t.Errorf("GET %s expected status %d, got: %d", target, want, got)
+		}
+	}
+
+	rejected := map[string]string{
+		"/api/posts?help":     "unknown query parameter help",
+		"/api/posts?offset=2": "not offset",
+	}
+	for target, want := range rejected {
+		recorder := testAPIRequest(t, handler, http.MethodGet, target, apiKey, "")
+		if body := recorder.Body.String(); recorder.Code != http.StatusBadRequest || !strings.Contains(body, want) {
+			t.Errorf("GET %s expected status 400 mentioning %q, got: %d %s", target, want, recorder.Code, body)
+		}
+	}
+}
+
+func TestAPI_GetAndDeleteFeed(t *testing.T) {
+	store := newFakeStore()
+	alice, aliceKey := store.addUser(t, "alice", false)
+	_, bobKey := store.addUser(t, "bob", false)
+	feed := database.Feed{ID: uuid.New(), Name: "Go blog", Url: "https://go.dev/blog/feed.atom", UserID: alice.ID}
+	target := database.Feed{ID: uuid.New(), Name: "Go news", Url: "https://go.dev/news.atom", UserID: alice.ID}
+	store.feeds = append(store.feeds, feed, target)
+	store.follows = append(store.follows, database.FeedFollow{FeedID: feed.ID, UserID: alice.ID})
+	store.posts = append(store.posts, database.GetPostByIdRow{ID: uuid.New(), FeedID: feed.ID, PublishedAt: time.Now()})
+	handler := newAPIServer(store, testFeedClient(t), retentionPolicy{}).routes()
+	feedPath := "/api/feeds/" + feed.ID.String()
+
+	got := decodeTestResponse[apiFeed](t, testAPIRequest(t, handler, http.MethodGet, feedPath, bobKey, ""))
+	if got.ID != feed.ID || got.Url != feed.Url {
+		t.Errorf("expected the feed, got: %+v", got)
+	}
+
+	expected := map[string]int{
+		"/api/feeds/" + uuid.NewString():                  http.StatusNotFound,
+		"/api/feeds/not-an-id":                            http.StatusBadRequest,
+		feedPath + "?posts=keep":                          http.StatusBadRequest,
+		feedPath + "?posts=move":                          http.StatusBadRequest,
+		feedPath + "?to=" + target.Url:                    http.StatusBadRequest,
+		feedPath + "?posts=move&to=" + feed.Url:           http.StatusBadRequest,
+		feedPath + "?posts=move&to=https://example.com/x": http.StatusNotFound,
+	}
+	for target, want := range expected {
+		if got := testAPIRequest(t, handler, http.MethodDelete, target, aliceKey, "").Code; got != want {
+			t.Errorf("DELETE %s expected status %d, got: %d", target, want, got)
+		}
+	}
+	if code := testAPIRequest(t, handler, http.MethodDelete, feedPath, bobKey, "").Code; code != http.StatusForbidden {
+		t.Errorf("expected status 403 when removing a feed added by someone else, got: %d", code)
+	}
+
+	moved := testAPIRequest(t, handler, http.MethodDelete, feedPath+"?posts=move&to="+target.Url, aliceKey, "")
+	if moved.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got: %d %s", moved.Code, moved.Body)
+	}
+	if len(store.feeds) != 1 || len(store.follows) != 0 || store.posts[0].FeedID != target.ID {
+		t.Errorf("expected the feed and its follow gone and its post moved, got: %+v %+v %+v", store.feeds, store.follows, store.posts)
+	}
+
+	archived := testAPIRequest(t, handler, http.MethodDelete, "/api/feeds/"+target.ID.String()+"?posts=archive", aliceKey, "")
+	if archived.Code != http.StatusNoContent || !store.feeds[0].Archived || len(store.posts) != 1 {
+		t.Errorf("expected the feed archived with its post, got: %d %+v", archived.Code, store.feeds)
+	}
+
+	deleted := testAPIRequest(t, handler, http.MethodDelete, "/api/feeds/"+target.ID.String(), aliceKey, "")
+	if deleted.Code != http.StatusNoContent || len(store.feeds) != 0 || len(store.posts) != 0 {
+		t.Errorf("expected the feed and its posts deleted, got: %d %+v %+v", deleted.Code, store.feeds, store.posts)
+	}
+}
+
+func TestAPI_DeleteUser(t *testing.T) {
+	store := newFakeStore()
+	admin, adminKey := store.addUser(t, "alice", true)
+	other, otherKey := store.addUser(t, "bob", false)
+	handler := newAPIServer(store, testFeedClient(t), retentionPolicy{}).routes()
+
+	if code := testAPIRequest(t, handler, http.MethodDelete, "/api/users/"+admin.ID.String(), otherKey, "").Code; code != http.StatusForbidden {
+		t.Errorf("expected status 403 when deleting someone else, got: %d", code)
+	}
+	if code := testAPIRequest(t, handler, http.MethodDelete, "/api/users/"+other.ID.String(), adminKey, "").Code; code != http.StatusNoContent {
+		t.Errorf("expected admins to delete other users, got: %d", code)
+	}
+	if len(store.users) != 1 {
+		t.Errorf("expected one user left, got: %d", len(store.users))
+	}
+}
+
+func TestAPI_OutputFeed(t *testing.T) {
+	store := newFakeStore()
+	user, apiKey := store.addUser(t, "alice", false)
+	feedID := uuid.New()
+	store.feeds = append(store.feeds, database.Feed{ID: feedID, Name: "Go blog", Url: "https://go.dev/blog/feed.atom"})
+	store.follows = append(store.follows, database.FeedFollow{FeedID: feedID, UserID: user.ID, Category: "tech/go"})
+	store.posts = append(store.posts, database.GetPostByIdRow{
+		ID: uuid.New(), Title: "Go 1.24", Url: "https://go.dev/blog/go1.24", FeedID: feedID, PublishedAt: time.Now(),
+	})
+	handler := newAPIServer(store, testFeedClient(t), retentionPolicy{}).routes()
+
+	recorder := testAPIRequest(t, handler, http.MethodGet, "/api/output/atom/tech?api_key="+apiKey, "", "")
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/atom+xml") {
+		t.Fatalf("expected an atom document, got: %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	feed, err := parseFeed(recorder.Body.Bytes())
+	if err != nil {
+		t.Fatalf("parseFeed() returned unexpected error: %v", err)
+	}
+	if len(feed.Channel.Item) != 1 || feed.Channel.Link != "http://example.com/" {
+		t.Errorf("expected the followed post and a link to the site, got: %+v", feed.Channel)
+	}
+	if !strings.Contains(recorder.Body.String(), `<link href="http://example.com/api/output/atom/tech" rel="self"`) {
+		t.Errorf("expected a self link without the key, got:\n%s", recorder.Body)
+	}
+
+	expected := map[string]int{
+		"/api/output/rss":                         http.StatusOK,
+		"/api/output/rss/music":                   http.StatusOK,
+		"/api/output/json":                        http.StatusNotFound,
+		"/api/output/rss?limit=0":                 http.StatusBadRequest,
+		"/api/output/rss?api_key=" + apiKeyPrefix: http.StatusOK,
+	}
+	for target, want := range expected {
+		if got := testAPIRequest(t, handler, http.MethodGet, target, apiKey, "").Code; got != want {
+			t.Errorf("GET %s expected status %d, got: %d", target, want, got)
+		}
+	}
+	if code := testAPIRequest(t, handler, http.MethodGet, "/api/output/rss?api_key=forged", "", "").Code; code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 for an unknown key, got: %d", code)
+	}
+}
@@ -9,24 +9,19 @@ VALUES (
 )
 RETURNING *;
 
--- name: MarkFeedFetched :exec
-update feeds set last_fetched_at = $1 where feeds.url = $2;
-
--- name: GetNextFeedToFetched :one
-select * from feeds order by last_fetched_at asc nulls first limit 1;
-
 -- name: GetFeeds :many
 select * from feeds;
 
 -- name: CreateFeedFollow :one
 with inserted_feed_follow as (
-    INSERT INTO feed_follows (id, created_at, updated_at, feed_id, user_id)
+    INSERT INTO feed_follows (id, created_at, updated_at, feed_id, user_id, category)
     VALUES (
         $1,
         $2,
         $3,
         $4,
-        $5
+        $5,
+        $6
     )
     RETURNING *
 )
@@ -41,14 +36,66 @@ from inserted_feed_follow
 -- name: GetFeedByUrl :one
 select * from feeds where url = $1;
 
+-- name: GetFeedById :one
+select * from feeds where id = $1;
+
 -- name: GetFeedFollowsForUser :many
 select
     feed_follows.feed_id,
     feed_follows.user_id,
+    feed_follows.category,
     feeds.name as feed_name,
     feeds.url as feed_url
 from feed_follows
     inner join feeds on feeds.id = feed_follows.feed_id where feed_follows.user_id = $1;
 
 -- name: DeleteFollow :exec
-delete from feed_follows where user_id = $1 and feed_id = $2;
\ No newline at end of file
+delete from feed_follows where user_id = $1 and feed_id = $2;
+
+-- name: ClaimFeedsToFetch :many
+update feeds set last_fetched_at = sqlc.arg(fetched_at)::timestamp
+where id in (
+    select id from feeds
+    where not disabled
+      and (last_fetched_at is null or last_fetched_at < sqlc.arg(stale_before)::timestamp)
+      and (next_fetch_at is null or next_fetch_at <= sqlc.arg(fetched_at)::timestamp)
+    order by last_fetched_at asc nulls first
+    limit sqlc.arg(batch_size)
+    for update skip locked
+)
+RETURNING *;
+
+-- name: UpdateFeedCache :exec
+update feeds set etag = $1, last_modified = $2 where id = $3;
+
+-- name: RecordFeedFailure :exec
+update feeds set consecutive_failures = $1, last_error = $2, next_fetch_at = $3, disabled = $4 where id = $5;
+
+-- name: RecordFeedSuccess :exec
+update feeds set consecutive_failures = 0, last_error = '', next_fetch_at = null where id = $1;
+
+-- name: EnableFeed :exec
+update feeds set consecutive_failures = 0, last_error = '', next_fetch_at = null, disabled = false where id = $1;
+
+-- name: UpdateFeedUrl :exec
+update feeds set url = $1 where id = $2;
+
+-- name: DeleteFeed :exec
+delete from feeds where id = $1;
+
+-- name: MoveFeedFollows :exec
+update feed_follows set feed_id = sqlc.arg(to_feed_id)
+where feed_id = sqlc.arg(from_feed_id)
+  and user_id not in (select user_id from feed_follows where feed_id = sqlc.arg(to_feed_id));
+
+-- name: UpdateFeedMetadata :exec
+update feeds set link = $1, description = $2, image_url = $3 where id = $4;
+
+-- name: RenameFeed :exec
+update feeds set name = $1 where id = $2;
+
+-- name: ArchiveFeed :exec
+update feeds set archived = true, disabled = true, consecutive_failures = 0, next_fetch_at = null where id = $1;
+
+-- name: DeleteFeedFollows :exec
+delete from feed_follows where feed_id = $1;
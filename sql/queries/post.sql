@@ -1,16 +1,118 @@
--- name: CreatePost :exec
+-- name: CreatePost :execrows
 INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1,
-        $2,
-        $3,
-        $4,
-        $5,
-        $6,
-        $7,
-        $8
-)
-ON CONFLICT (url) DO NOTHING
-RETURNING *;
+SELECT sqlc.arg(id)::uuid,
+       sqlc.arg(created_at)::timestamp,
+       sqlc.arg(updated_at)::timestamp,
+       sqlc.arg(title)::text,
+       sqlc.arg(url)::text,
+       sqlc.arg(description)::text,
+       sqlc.arg(published_at)::timestamp,
+       sqlc.arg(feed_id)::uuid
+WHERE NOT EXISTS (select 1 from pruned_posts where pruned_posts.url = sqlc.arg(url))
+ON CONFLICT (url) DO NOTHING;
 
 -- name: GetPosts :many
-select * from posts order by created_at desc limit $1;
\ No newline at end of file
+select id, created_at, updated_at, title, url, description, published_at, feed_id from posts order by created_at desc limit $1;
+
+-- name: MovePostsToFeed :exec
+update posts set feed_id = sqlc.arg(to_feed_id) where feed_id = sqlc.arg(from_feed_id);
+
+-- name: GetPostById :one
+select id, created_at, updated_at, title, url, description, published_at, feed_id from posts where id = $1;
+
+-- name: GetPostByUrl :one
+select id, created_at, updated_at, title, url, description, published_at, feed_id from posts where url = $1;
+
+-- name: SearchPosts :many
+select posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name as feed_name, ts_rank(posts.search_vector, query)::real as rank
+from posts
+    inner join feeds on feeds.id = posts.feed_id,
+    to_tsquery('english', sqlc.arg(query)) query
+where posts.search_vector @@ query
+  and (sqlc.narg(feed_id)::uuid is null or posts.feed_id = sqlc.narg(feed_id))
+  and (not sqlc.arg(only_followed)::boolean or exists (
+        select 1 from feed_follows where feed_follows.feed_id = posts.feed_id and feed_follows.user_id = sqlc.arg(user_id)
+    ))
+order by rank desc, posts.published_at desc
+limit sqlc.arg(post_limit);
+
+-- name: BrowsePostsNewestFirst :many
+select posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name as feed_name, (post_reads.post_id is not null)::boolean as is_read
+from posts
+    inner join feeds on feeds.id = posts.feed_id
+    left join post_reads on post_reads.post_id = posts.id and post_reads.user_id = sqlc.arg(user_id)
+where (sqlc.arg(all_feeds)::boolean or exists (
+        select 1 from feed_follows where feed_follows.feed_id = posts.feed_id and feed_follows.user_id = sqlc.arg(user_id)
+    ))
+  and (sqlc.arg(include_read)::boolean or post_reads.post_id is null)
+  and (sqlc.narg(feed_id)::uuid is null or posts.feed_id = sqlc.narg(feed_id))
+  and (sqlc.narg(since)::timestamp is null or posts.published_at >= sqlc.narg(since))
+  and (sqlc.narg(until)::timestamp is null or posts.published_at < sqlc.narg(until))
+  and (sqlc.narg(cursor_published_at)::timestamp is null
+    or (posts.published_at, posts.id) < (sqlc.narg(cursor_published_at), sqlc.narg(cursor_id)::uuid))
+order by posts.published_at desc, posts.id desc
+limit sqlc.arg(post_limit) offset sqlc.arg(post_offset);
+
+-- name: BrowsePostsOldestFirst :many
+select posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name as feed_name, (post_reads.post_id is not null)::boolean as is_read
+from posts
+    inner join feeds on feeds.id = posts.feed_id
+    left join post_reads on post_reads.post_id = posts.id and post_reads.user_id = sqlc.arg(user_id)
+where (sqlc.arg(all_feeds)::boolean or exists (
+        select 1 from feed_follows where feed_follows.feed_id = posts.feed_id and feed_follows.user_id = sqlc.arg(user_id)
+    ))
+  and (sqlc.arg(include_read)::boolean or post_reads.post_id is null)
+  and (sqlc.narg(feed_id)::uuid is null or posts.feed_id = sqlc.narg(feed_id))
+  and (sqlc.narg(since)::timestamp is null or posts.published_at >= sqlc.narg(since))
+  and (sqlc.narg(until)::timestamp is null or posts.published_at < sqlc.narg(until))
+  and (sqlc.narg(cursor_published_at)::timestamp is null
+    or (posts.published_at, posts.id) > (sqlc.narg(cursor_published_at), sqlc.narg(cursor_id)::uuid))
+order by posts.published_at asc, posts.id asc
+limit sqlc.arg(post_limit) offset sqlc.arg(post_offset);
+
+-- name: GetFollowedPosts :many
+select posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name as feed_name, feeds.url as feed_url, feed_follows.category
+from posts
+    inner join feed_follows on feed_follows.feed_id = posts.feed_id and feed_follows.user_id = sqlc.arg(user_id)
+    inner join feeds on feeds.id = posts.feed_id
+where (sqlc.narg(category)::text is null
+    or feed_follows.category = sqlc.narg(category)
+    or starts_with(feed_follows.category, sqlc.narg(category) || '/'))
+order by posts.published_at desc, posts.id desc
+limit sqlc.arg(post_limit);
+
+-- name: DeletePostsOfFeed :execrows
+delete from posts
+where feed_id = $1
+  and not exists (select 1 from saved_posts where saved_posts.post_id = posts.id);
+
+-- name: CountPostsOfFeed :one
+select count(*) from posts where feed_id = $1;
+
+-- name: PruneExpiredPosts :execrows
+with pruned as (
+    delete from posts
+    where published_at < sqlc.arg(published_before)
+      and not exists (select 1 from saved_posts where saved_posts.post_id = posts.id)
+    returning url
+)
+insert into pruned_posts (url, pruned_at)
+select url, now() from pruned
+on conflict (url) do update set pruned_at = excluded.pruned_at;
+
+-- name: PruneExcessPosts :execrows
+with pruned as (
+    delete from posts
+    where id in (
+        select ranked.id from (
+            select posts.id, row_number() over (partition by posts.feed_id order by posts.published_at desc, posts.id desc) as position
+            from posts
+            where not exists (select 1 from saved_posts where saved_posts.post_id = posts.id)
+        ) ranked
+        where ranked.position > sqlc.arg(max_posts_per_feed)::bigint
+    )
+    returning url
+)
+insert into pruned_posts (url, pruned_at)
+select url, now() from pruned
+on conflict (url) do update set pruned_at = excluded.pruned_at;
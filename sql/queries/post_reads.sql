@@ -0,0 +1,30 @@
+-- name: MarkPostRead :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostUnread :exec
+delete from post_reads where user_id = $1 and post_id = $2;
+
+-- name: MarkAllPostsRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+select sqlc.arg(user_id)::uuid, posts.id, sqlc.arg(read_at)::timestamp
+from posts
+    inner join feed_follows on feed_follows.feed_id = posts.feed_id and feed_follows.user_id = sqlc.arg(user_id)
+where sqlc.narg(feed_id)::uuid is null or posts.feed_id = sqlc.narg(feed_id)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: GetUnreadCountsForUser :many
+select
+    feed_follows.feed_id,
+    feed_follows.category,
+    feeds.name as feed_name,
+    feeds.url as feed_url,
+    (count(posts.id) filter (where post_reads.post_id is null))::int as unread_count
+from feed_follows
+    inner join feeds on feeds.id = feed_follows.feed_id
+    left join posts on posts.feed_id = feed_follows.feed_id
+    left join post_reads on post_reads.post_id = posts.id and post_reads.user_id = feed_follows.user_id
+where feed_follows.user_id = $1
+group by feed_follows.feed_id, feed_follows.category, feeds.name, feeds.url
+order by feed_follows.category, feeds.name;
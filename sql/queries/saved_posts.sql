@@ -0,0 +1,22 @@
+-- name: SavePost :exec
+INSERT INTO saved_posts (user_id, post_id, tags, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, post_id) DO UPDATE
+    set tags = array(select distinct unnest(saved_posts.tags || excluded.tags) order by 1);
+
+-- name: UnsavePost :exec
+delete from saved_posts where user_id = $1 and post_id = $2;
+
+-- name: GetSavedPosts :many
+select
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    saved_posts.tags,
+    saved_posts.created_at as saved_at
+from saved_posts
+    inner join posts on posts.id = saved_posts.post_id
+where saved_posts.user_id = sqlc.arg(user_id)
+  and (sqlc.narg(tag)::text is null or saved_posts.tags @> array[sqlc.narg(tag)::text])
+order by saved_posts.created_at desc;
@@ -1,21 +1,38 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, is_admin, api_key_hash)
 VALUES (
    $1,
    $2,
    $3,
-   $4
+   $4,
+   $5,
+   $6
 )
 RETURNING *;
 
 -- name: GetUser :one
 select * from users where name = $1;
 
+-- name: GetUserByApiKeyHash :one
+select * from users where api_key_hash = $1;
+
 -- name: GetUserById :one
 select * from users where id = $1;
 
+-- name: DeleteUser :exec
+delete from users where id = $1;
+
 -- name: DeleteUsers :exec
 delete from users;
 
 -- name: GetUsers :many
-select * from users;
\ No newline at end of file
+select * from users;
+
+-- name: CountUsers :one
+select count(*) from users;
+
+-- name: SetUserAdmin :exec
+update users set is_admin = $1, updated_at = $2 where id = $3;
+
+-- name: SetUserApiKeyHash :exec
+update users set api_key_hash = $1, updated_at = $2 where id = $3;
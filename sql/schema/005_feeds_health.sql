@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN consecutive_failures integer not null default 0;
+ALTER TABLE feeds ADD COLUMN last_error text not null default '';
+ALTER TABLE feeds ADD COLUMN next_fetch_at timestamp;
+ALTER TABLE feeds ADD COLUMN disabled boolean not null default false;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN disabled;
+ALTER TABLE feeds DROP COLUMN next_fetch_at;
+ALTER TABLE feeds DROP COLUMN last_error;
+ALTER TABLE feeds DROP COLUMN consecutive_failures;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE post_reads (
+    user_id uuid not null,
+    post_id uuid not null,
+    read_at timestamp not null,
+    primary key (user_id, post_id),
+    foreign key (user_id) references users(id) on delete cascade,
+    foreign key (post_id) references posts(id) on delete cascade
+);
+
+-- +goose Down
+DROP TABLE post_reads;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN is_admin boolean not null default false;
+-- on an existing database the first registered user becomes the admin, as register does on an empty one
+update users set is_admin = true where id = (select id from users order by created_at limit 1);
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN is_admin;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE saved_posts (
+    user_id uuid not null,
+    post_id uuid not null,
+    tags text[] not null default '{}',
+    created_at timestamp not null,
+    primary key (user_id, post_id),
+    foreign key (user_id) references users(id) on delete cascade,
+    foreign key (post_id) references posts(id) on delete cascade
+);
+
+CREATE INDEX saved_posts_tags_idx ON saved_posts USING GIN (tags);
+
+-- +goose Down
+DROP TABLE saved_posts;
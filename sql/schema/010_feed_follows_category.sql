@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE feed_follows ADD COLUMN category text not null default '';
+
+-- +goose Down
+ALTER TABLE feed_follows DROP COLUMN category;
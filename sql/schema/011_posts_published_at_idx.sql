@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX posts_published_at_id_idx ON posts (published_at, id);
+
+-- +goose Down
+DROP INDEX posts_published_at_id_idx;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN api_key_hash text unique;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN api_key_hash;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN link text not null default '';
+ALTER TABLE feeds ADD COLUMN description text not null default '';
+ALTER TABLE feeds ADD COLUMN image_url text not null default '';
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN image_url;
+ALTER TABLE feeds DROP COLUMN description;
+ALTER TABLE feeds DROP COLUMN link;
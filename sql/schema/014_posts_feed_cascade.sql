@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE posts DROP CONSTRAINT posts_feed_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_feed_id_fkey foreign key (feed_id) references feeds(id) on delete cascade;
+-- used by the cascade and by the per feed retention
+CREATE INDEX posts_feed_id_published_at_idx ON posts (feed_id, published_at);
+
+-- +goose Down
+DROP INDEX posts_feed_id_published_at_idx;
+ALTER TABLE posts DROP CONSTRAINT posts_feed_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_feed_id_fkey foreign key (feed_id) references feeds(id);
@@ -0,0 +1,6 @@
+-- +goose Up
+-- the url already identifies a post, entries without a summary all share the empty description
+ALTER TABLE posts DROP CONSTRAINT posts_description_key;
+
+-- +goose Down
+ALTER TABLE posts ADD CONSTRAINT posts_description_key unique (description);
@@ -0,0 +1,6 @@
+-- +goose Up
+-- archived feeds (removefeed --posts archive) keep their posts but are never fetched or followed again
+ALTER TABLE feeds ADD COLUMN archived boolean not null default false;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN archived;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- urls removed by retention, so the next fetch does not import them again
+CREATE TABLE pruned_posts (
+    url text primary key,
+    pruned_at timestamp not null
+);
+
+-- +goose Down
+DROP TABLE pruned_posts;
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ANSI escape sequences used by the tui
+const (
+	ansiAltScreen   = "\x1b[?1049h"
+	ansiMainScreen  = "\x1b[?1049l"
+	ansiHideCursor  = "\x1b[?25l"
+	ansiShowCursor  = "\x1b[?25h"
+	ansiHome        = "\x1b[H"
+	ansiClearScreen = "\x1b[2J"
+	ansiReset       = "\x1b[0m"
+	ansiBold        = "\x1b[1m"
+	ansiUnderline   = "\x1b[4m"
+	ansiReverse     = "\x1b[7m"
+)
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+/*
+*
+This method switches the terminal behind stdin to raw mode with stty, the returned function restores the previous settings.
+*/
+func enableRawMode() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read terminal settings: %v", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("cannot switch terminal to raw mode: %v", err)
+	}
+
+	return func() {
+		_, _ = stty(strings.TrimSpace(saved))
+	}, nil
+}
+
+// rows and columns of the terminal, 24x80 when stty cannot tell
+func terminalSize() (int, int) {
+	output, err := stty("size")
+	if err != nil {
+		return 24, 80
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return 24, 80
+	}
+	rows, rowsErr := strconv.Atoi(fields[0])
+	cols, colsErr := strconv.Atoi(fields[1])
+	if rowsErr != nil || colsErr != nil || rows < 1 || cols < 1 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+	return string(output), err
+}
+
+/*
+*
+This method maps the bytes of one read from a raw terminal to a key name, multi byte escape sequences are the arrow keys.
+*/
+func parseKey(input []byte) string {
+	switch string(input) {
+	case "\x1b[A", "\x1bOA":
+		return "up"
+	case "\x1b[B", "\x1bOB":
+		return "down"
+	case "\x1b[C", "\x1bOC":
+		return "right"
+	case "\x1b[D", "\x1bOD":
+		return "left"
+	case "\x1b[5~":
+		return "pgup"
+	case "\x1b[6~":
+		return "pgdown"
+	case "\r", "\n":
+		return "enter"
+	case "\t":
+		return "tab"
+	case "\x1b":
+		return "esc"
+	case "\x03":
+		return "ctrl+c"
+	case " ":
+		return "space"
+	}
+	return string(input)
+}
+
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
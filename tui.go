@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	tuiPostLimit     = 200
+	tuiMaxFeedsWidth = 32
+)
+
+type tuiPane int
+
+const (
+	feedsPane tuiPane = iota
+	postsPane
+	previewPane
+)
+
+type tuiAction int
+
+const (
+	tuiNone tuiAction = iota
+	tuiQuit
+	tuiLoadPosts
+	tuiShowPost
+	tuiToggleRead
+	tuiOpenBrowser
+	tuiRefresh
+)
+
+/*
+The first feed of the list is the "All feeds" entry, its ID is not valid.
+*/
+type tuiFeed struct {
+	ID       uuid.NullUUID
+	Name     string
+	Category string
+	Unread   int
+}
+
+/*
+tuiModel holds what the reader shows, handleKey and render never touch the database or the terminal
+so the navigation can be tested without either.
+*/
+type tuiModel struct {
+	userName      string
+	feeds         []tuiFeed
+	feedIdx       int
+	posts         []database.BrowsePostsNewestFirstRow
+	postIdx       int
+	focus         tuiPane
+	previewScroll int
+	status        string
+}
+
+func newTuiModel(userName string, counts []database.GetUnreadCountsForUserRow) *tuiModel {
+	model := &tuiModel{userName: userName}
+	model.setFeeds(counts)
+	return model
+}
+
+func (m *tuiModel) setFeeds(counts []database.GetUnreadCountsForUserRow) {
+	var selected uuid.NullUUID
+	if m.feedIdx < len(m.feeds) {
+		selected = m.feeds[m.feedIdx].ID
+	}
+
+	all := tuiFeed{Name: "All feeds"}
+	m.feeds = []tuiFeed{all}
+	for _, count := range counts {
+		m.feeds[0].Unread += int(count.UnreadCount)
+		m.feeds = append(m.feeds, tuiFeed{
+			ID:       uuid.NullUUID{UUID: count.FeedID, Valid: true},
+			Name:     count.FeedName,
+			Category: count.Category,
+			Unread:   int(count.UnreadCount),
+		})
+	}
+
+	// keep the cursor on the same feed after a refresh
+	m.feedIdx = 0
+	for idx, feed := range m.feeds {
+		if feed.ID == selected {
+			m.feedIdx = idx
+		}
+	}
+}
+
+func (m *tuiModel) setPosts(posts []database.BrowsePostsNewestFirstRow) {
+	m.posts = posts
+	m.postIdx = 0
+	m.previewScroll = 0
+}
+
+func (m *tuiModel) selectedFeed() tuiFeed {
+	return m.feeds[m.feedIdx]
+}
+
+func (m *tuiModel) selectedPost() (database.BrowsePostsNewestFirstRow, bool) {
+	if m.postIdx >= len(m.posts) {
+		return database.BrowsePostsNewestFirstRow{}, false
+	}
+	return m.posts[m.postIdx], true
+}
+
+/*
+*
+This method flips the read flag of the selected post and keeps the unread counts in sync, the caller persists it.
+*/
+func (m *tuiModel) setRead(read bool) {
+	post, ok := m.selectedPost()
+	if !ok || post.IsRead == read {
+		return
+	}
+	m.posts[m.postIdx].IsRead = read
+
+	delta := 1
+	if read {
+		delta = -1
+	}
+	for idx := range m.feeds {
+		if !m.feeds[idx].ID.Valid || m.feeds[idx].ID.UUID == post.FeedID {
+			m.feeds[idx].Unread += delta
+		}
+	}
+}
+
+/*
+*
+This method applies a key to the model and returns what the caller has to do with the database or the terminal.
+*/
+func (m *tuiModel) handleKey(key string) tuiAction {
+	m.status = ""
+	switch key {
+	case "q", "ctrl+c":
+		return tuiQuit
+	case "tab":
+		m.focus = (m.focus + 1) % 3
+		if m.focus == previewPane {
+			return tuiShowPost
+		}
+	case "left", "h", "esc":
+		if m.focus > feedsPane {
+			m.focus--
+		}
+	case "right", "l", "enter":
+		switch m.focus {
+		case feedsPane:
+			m.focus = postsPane
+		case postsPane:
+			if _, ok := m.selectedPost(); ok {
+				m.focus = previewPane
+				return tuiShowPost
+			}
+		}
+	case "up", "k":
+		return m.move(-1)
+	case "down", "j":
+		return m.move(1)
+	case "pgup":
+		return m.move(-10)
+	case "pgdown", "space":
+		return m.move(10)
+	case "m":
+		if _, ok := m.selectedPost(); ok {
+			return tuiToggleRead
+		}
+	case "o":
+		if _, ok := m.selectedPost(); ok {
+			return tuiOpenBrowser
+		}
+	case "r":
+		return tuiRefresh
+	}
+	return tuiNone
+}
+
+func (m *tuiModel) move(delta int) tuiAction {
+	switch m.focus {
+	case feedsPane:
+		next := clamp(m.feedIdx+delta, 0, len(m.feeds)-1)
+		if next == m.feedIdx {
+			return tuiNone
+		}
+		m.feedIdx = next
+		return tuiLoadPosts
+	case postsPane:
+		m.postIdx = clamp(m.postIdx+delta, 0, len(m.posts)-1)
+		m.previewScroll = 0
+	case previewPane:
+		m.previewScroll = max(m.previewScroll+delta, 0)
+	}
+	return tuiNone
+}
+
+func clamp(value, low, high int) int {
+	if high < low {
+		return low
+	}
+	return min(max(value, low), high)
+}
+
+/*
+*
+This method draws the whole screen as height lines of at most width visible characters:
+feeds on the left, posts above the preview on the right, a status line at the bottom.
+*/
+func (m *tuiModel) render(width, height int) []string {
+	if width < 20 || height < 6 {
+		return []string{fit("terminal too small", width)}
+	}
+
+	feedsWidth := min(tuiMaxFeedsWidth, width/3)
+	rightWidth := width - feedsWidth - 1
+	bodyHeight := height - 2
+	listHeight := bodyHeight / 2
+	previewHeight := bodyHeight - listHeight - 1
+
+	feedLines := m.renderFeeds(feedsWidth, bodyHeight)
+	rightLines := append(m.renderPosts(rightWidth, listHeight), strings.Repeat("─", rightWidth))
+	rightLines = append(rightLines, m.renderPreview(rightWidth, previewHeight)...)
+
+	feed := m.selectedFeed()
+	header := fmt.Sprintf(" gator | %s | %s (%d unread)", m.userName, feed.Name, feed.Unread)
+	lines := []string{ansiReverse + fit(header, width) + ansiReset}
+	for idx := 0; idx < bodyHeight; idx++ {
+		lines = append(lines, feedLines[idx]+"│"+rightLines[idx])
+	}
+
+	footer := m.status
+	if footer == "" {
+		footer = "↑↓ move  tab/←→ pane  enter read  m toggle read  o open in browser  r refresh  q quit"
+	}
+	return append(lines, fit(footer, width))
+}
+
+func (m *tuiModel) renderFeeds(width, height int) []string {
+	offset := scrollOffset(m.feedIdx, len(m.feeds), height)
+	lines := make([]string, 0, height)
+	for idx := offset; idx < offset+height; idx++ {
+		if idx >= len(m.feeds) {
+			lines = append(lines, fit("", width))
+			continue
+		}
+
+		feed := m.feeds[idx]
+		count := ""
+		if feed.Unread > 0 {
+			count = fmt.Sprintf(" %d", feed.Unread)
+		}
+		name := feed.Name
+		if feed.Category != "" {
+			name = feed.Category + categorySeparator + name
+		}
+		line := fit(" "+name, width-len(count)) + count
+		lines = append(lines, m.highlight(line, idx == m.feedIdx, feedsPane, feed.Unread > 0))
+	}
+	return lines
+}
+
+func (m *tuiModel) renderPosts(width, height int) []string {
+	if len(m.posts) == 0 {
+		lines := []string{fit(" No post in this feed", width)}
+		for len(lines) < height {
+			lines = append(lines, fit("", width))
+		}
+		return lines
+	}
+
+	offset := scrollOffset(m.postIdx, len(m.posts), height)
+	lines := make([]string, 0, height)
+	for idx := offset; idx < offset+height; idx++ {
+		if idx >= len(m.posts) {
+			lines = append(lines, fit("", width))
+			continue
+		}
+
+		post := m.posts[idx]
+		marker := " "
+		if !post.IsRead {
+			marker = "*"
+		}
+		line := fit(fmt.Sprintf("%s %s  %s", marker, post.PublishedAt.Format(time.DateOnly), post.Title), width)
+		lines = append(lines, m.highlight(line, idx == m.postIdx, postsPane, !post.IsRead))
+	}
+	return lines
+}
+
+func (m *tuiModel) renderPreview(width, height int) []string {
+	var content []string
+	if post, ok := m.selectedPost(); ok {
+		content = append(content, ansiBold+fit(post.Title, width)+ansiReset)
+		content = append(content, fit(fmt.Sprintf("%s | %s", post.FeedName, post.PublishedAt.Format(time.RFC1123)), width))
+		content = append(content, fit(post.Url, width), fit("", width))
+		for _, line := range wrapText(plainText(post.Description), width) {
+			content = append(content, fit(line, width))
+		}
+	}
+
+	m.previewScroll = clamp(m.previewScroll, 0, len(content)-height)
+	lines := make([]string, 0, height)
+	for idx := m.previewScroll; idx < m.previewScroll+height; idx++ {
+		if idx < len(content) {
+			lines = append(lines, content[idx])
+		} else {
+			lines = append(lines, fit("", width))
+		}
+	}
+	return lines
+}
+
+func (m *tuiModel) highlight(line string, selected bool, pane tuiPane, unread bool) string {
+	switch {
+	case selected && m.focus == pane:
+		return ansiReverse + line + ansiReset
+	case selected:
+		return ansiUnderline + line + ansiReset
+	case unread:
+		return ansiBold + line + ansiReset
+	}
+	return line
+}
+
+// first visible index of a list so the selected row stays on screen
+func scrollOffset(selected, total, height int) int {
+	if selected < height {
+		return 0
+	}
+	return min(selected-height+1, total-height)
+}
+
+/*
+*
+This method cuts or pads a single line to exactly width runes, control characters are replaced by spaces.
+*/
+func fit(value string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	runes := []rune(strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, value))
+	if len(runes) > width {
+		if width == 1 {
+			return "…"
+		}
+		return string(runes[:width-1]) + "…"
+	}
+	return string(runes) + strings.Repeat(" ", width-len(runes))
+}
+
+var (
+	htmlBreakTags = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/h[1-6]|/blockquote|/tr)\b[^>]*>`)
+	htmlTags      = regexp.MustCompile(`<[^>]*>`)
+)
+
+/*
+*
+Feed descriptions are mostly HTML, the preview shows their text with one paragraph per line.
+*/
+func plainText(value string) string {
+	value = htmlBreakTags.ReplaceAllString(value, "\n")
+	value = html.UnescapeString(htmlTags.ReplaceAllString(value, ""))
+
+	var paragraphs []string
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+func wrapText(text string, width int) []string {
+	var lines []string
+	for idx, paragraph := range strings.Split(text, "\n") {
+		if idx > 0 {
+			lines = append(lines, "")
+		}
+
+		current := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len([]rune(word)) > width {
+				if current != "" {
+					lines = append(lines, current)
+					current = ""
+				}
+				runes := []rune(word)
+				lines = append(lines, string(runes[:width]))
+				word = string(runes[width:])
+			}
+
+			switch {
+			case current == "":
+				current = word
+			case len([]rune(current))+1+len([]rune(word)) <= width:
+				current += " " + word
+			default:
+				lines = append(lines, current)
+				current = word
+			}
+		}
+		if current != "" {
+			lines = append(lines, current)
+		}
+	}
+	return lines
+}
+
+func handlerTui(state *state, cmd command, user database.User) error {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return errors.New("tui needs an interactive terminal")
+	}
+
+	counts, err := state.dbQueriesData.GetUnreadCountsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error on handler tui get unread counts: %v", err)
+	}
+	model := newTuiModel(user.Name, counts)
+	if err := loadTuiPosts(state, user, model); err != nil {
+		return err
+	}
+
+	restore, err := enableRawMode()
+	if err != nil {
+		return fmt.Errorf("error on handler tui: %v", err)
+	}
+	fmt.Print(ansiAltScreen + ansiHideCursor)
+	defer func() {
+		fmt.Print(ansiShowCursor + ansiMainScreen)
+		restore()
+	}()
+
+	input := make([]byte, 16)
+	for {
+		rows, cols := terminalSize()
+		fmt.Print(ansiHome + ansiClearScreen + strings.Join(model.render(cols, rows), "\r\n"))
+
+		read, err := os.Stdin.Read(input)
+		if err != nil {
+			return fmt.Errorf("error on handler tui read key: %v", err)
+		}
+
+		switch model.handleKey(parseKey(input[:read])) {
+		case tuiQuit:
+			return nil
+		case tuiLoadPosts:
+			err = loadTuiPosts(state, user, model)
+		case tuiShowPost:
+			err = markTuiPost(state, user, model, true)
+		case tuiToggleRead:
+			post, _ := model.selectedPost()
+			err = markTuiPost(state, user, model, !post.IsRead)
+		case tuiOpenBrowser:
+			post, _ := model.selectedPost()
+			if err = openBrowser(post.Url); err == nil {
+				model.status = "Opened " + post.Url
+				err = markTuiPost(state, user, model, true)
+			}
+		case tuiRefresh:
+			counts, err = state.dbQueriesData.GetUnreadCountsForUser(context.Background(), user.ID)
+			if err == nil {
+				model.setFeeds(counts)
+				err = loadTuiPosts(state, user, model)
+			}
+		}
+		// database or browser errors are shown in the status line instead of closing the reader
+		if err != nil {
+			model.status = "Error: " + err.Error()
+		}
+	}
+}
+
+func loadTuiPosts(state *state, user database.User, model *tuiModel) error {
+	posts, err := state.dbQueriesData.BrowsePostsNewestFirst(context.Background(), database.BrowsePostsNewestFirstParams{
+		UserID:      user.ID,
+		IncludeRead: true,
+		FeedID:      model.selectedFeed().ID,
+		PostLimit:   tuiPostLimit,
+	})
+	if err != nil {
+		return fmt.Errorf("error on handler tui get posts: %v", err)
+	}
+	model.setPosts(posts)
+	return nil
+}
+
+func markTuiPost(state *state, user database.User, model *tuiModel, read bool) error {
+	post, ok := model.selectedPost()
+	if !ok || post.IsRead == read {
+		return nil
+	}
+
+	var err error
+	if read {
+		err = state.dbQueriesData.MarkPostRead(context.Background(), database.MarkPostReadParams{
+			UserID: user.ID,
+			PostID: post.ID,
+			ReadAt: time.Now(),
+		})
+	} else {
+		err = state.dbQueriesData.MarkPostUnread(context.Background(), database.MarkPostUnreadParams{
+			UserID: user.ID,
+			PostID: post.ID,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("cannot update post %s: %v", post.Title, err)
+	}
+
+	model.setRead(read)
+	return nil
+}
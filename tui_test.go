@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bootDevGoRss/internal/database"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[a-zA-Z]`)
+
+func testTuiModel() *tuiModel {
+	goFeed, rustFeed := uuid.New(), uuid.New()
+	model := newTuiModel("alice", []database.GetUnreadCountsForUserRow{
+		{FeedID: goFeed, FeedName: "Go blog", UnreadCount: 2},
+		{FeedID: rustFeed, FeedName: "Rust blog", Category: "lang", UnreadCount: 1},
+	})
+	model.setPosts([]database.BrowsePostsNewestFirstRow{
+		{ID: uuid.New(), FeedID: goFeed, Title: "Generics", FeedName: "Go blog", PublishedAt: time.Now(),
+			Description: "<p>Type parameters &amp; constraints</p><p>Second paragraph</p>"},
+		{ID: uuid.New(), FeedID: goFeed, Title: "Iterators", FeedName: "Go blog", PublishedAt: time.Now()},
+	})
+	return model
+}
+
+func TestTuiModel_UnreadCounts(t *testing.T) {
+	model := testTuiModel()
+
+	if len(model.feeds) != 3 || model.feeds[0].Unread != 3 {
+		t.Fatalf("expected all feeds entry with 3 unread posts, got: %+v", model.feeds)
+	}
+
+	model.setRead(true)
+	if model.feeds[0].Unread != 2 || model.feeds[1].Unread != 1 || model.feeds[2].Unread != 1 {
+		t.Errorf("expected the all and Go blog counts to drop, got: %+v", model.feeds)
+	}
+
+	model.setRead(true)
+	if model.feeds[0].Unread != 2 {
+		t.Errorf("marking a read post again must not change counts, got: %d", model.feeds[0].Unread)
+	}
+}
+
+func TestTuiModel_Navigation(t *testing.T) {
+	model := testTuiModel()
+
+	if action := model.handleKey("down"); action != tuiLoadPosts || model.feedIdx != 1 {
+		t.Errorf("expected down in feeds to load posts of the next feed, got: %v at %d", action, model.feedIdx)
+	}
+	if action := model.handleKey("up"); action != tuiLoadPosts || model.feedIdx != 0 {
+		t.Errorf("expected up to come back to the first feed, got: %v at %d", action, model.feedIdx)
+	}
+	if action := model.handleKey("up"); action != tuiNone {
+		t.Errorf("expected nothing to do at the top of the list, got: %v", action)
+	}
+
+	model.handleKey("enter")
+	if model.focus != postsPane {
+		t.Fatalf("expected enter to focus the posts, got: %v", model.focus)
+	}
+	model.handleKey("j")
+	if model.postIdx != 1 {
+		t.Errorf("expected second post selected, got: %d", model.postIdx)
+	}
+	model.handleKey("j")
+	if model.postIdx != 1 {
+		t.Errorf("expected selection to stop at the last post, got: %d", model.postIdx)
+	}
+	if action := model.handleKey("enter"); action != tuiShowPost || model.focus != previewPane {
+		t.Errorf("expected enter to show the post, got: %v in pane %v", action, model.focus)
+	}
+
+	expected := map[string]tuiAction{"m": tuiToggleRead, "o": tuiOpenBrowser, "r": tuiRefresh, "q": tuiQuit}
+	for key, want := range expected {
+		if got := model.handleKey(key); got != want {
+			t.Errorf("key %s expected action %v, got: %v", key, want, got)
+		}
+	}
+}
+
+func TestTuiModel_RenderFitsScreen(t *testing.T) {
+	model := testTuiModel()
+	model.posts[0].Title = strings.Repeat("very long title ", 20)
+
+	for _, size := range [][2]int{{80, 24}, {40, 10}, {200, 60}} {
+		lines := model.render(size[0], size[1])
+		if len(lines) != size[1] {
+			t.Errorf("render(%d, %d) expected %d lines, got: %d", size[0], size[1], size[1], len(lines))
+		}
+		for idx, line := range lines {
+			if width := utf8.RuneCountInString(ansiEscape.ReplaceAllString(line, "")); width != size[0] {
+				t.Errorf("render(%d, %d) line %d expected width %d, got: %d", size[0], size[1], idx, size[0], width)
+			}
+		}
+	}
+
+	// counts are right aligned, compare with collapsed spaces
+	screen := strings.Join(strings.Fields(ansiEscape.ReplaceAllString(strings.Join(model.render(100, 30), "\n"), "")), " ")
+	for _, want := range []string{"All feeds 3", "Go blog 2", "lang/Rust blog 1", "Type parameters & constraints"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("expected screen to contain %q, got:\n%s", want, screen)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	got := plainText("<p>Hello&nbsp;<b>world</b></p>\n\n<p>Line<br/>break &lt;3</p>")
+	want := "Hello world\nLine\nbreak <3"
+	if got != want {
+		t.Errorf("expected %q, got: %q", want, got)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	got := wrapText("the quick brown fox\nsupercalifragilistic", 10)
+	want := []string{"the quick", "brown fox", "", "supercalif", "ragilistic"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got: %q", want, got)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	expected := map[string]string{"\x1b[A": "up", "\x1bOB": "down", "\r": "enter", "\t": "tab", "\x03": "ctrl+c", "j": "j"}
+	for input, want := range expected {
+		if got := parseKey([]byte(input)); got != want {
+			t.Errorf("parseKey(%q) expected %s, got: %s", input, want, got)
+		}
+	}
+}